@@ -0,0 +1,176 @@
+// dts-audit 离线复算大逃杀的结算结果，用于处理玩家的派奖争议
+//
+// 按开局时的配置版本重新跑一遍派奖公式，和库里保存的 State / Bonus / 汇总字段逐项比对，
+// 加 -ledger 时再核对钱包流水里实际入账的派奖。只读数据库，可以直接连备份快照。
+//
+//	go run ./cmd/dts-audit -game 1024
+//	go run ./cmd/dts-audit -dsn 'user:pass@tcp(127.0.0.1:3306)/snapshot?parseTime=True' -from 1000 -to 1100 -ledger -json report.json
+//
+// 有任何差异时退出码为 1
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"test/internal/model"
+	"test/internal/service"
+	"test/pkg/config"
+	"test/pkg/database"
+)
+
+func main() {
+	dsn := flag.String("dsn", "", "MySQL 连接串，为空时读取 .env / config.yaml 的数据库配置")
+	gameID := flag.Uint("game", 0, "复算单局的游戏 ID")
+	from := flag.Uint("from", 0, "按范围复算：起始游戏 ID（含）")
+	to := flag.Uint("to", 0, "按范围复算：结束游戏 ID（含），为 0 表示不限")
+	ledger := flag.Bool("ledger", false, "同时核对钱包流水里的派奖入账")
+	jsonOut := flag.String("json", "", "JSON 报告的输出文件，- 表示标准输出（此时不再打印文本报告）")
+	flag.Parse()
+
+	if *gameID == 0 && *from == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *dsn == "" {
+		config.Load()
+		*dsn = database.DSN()
+	}
+	db, err := database.Open(*dsn)
+	if err != nil {
+		fail(err)
+	}
+	database.DB = db
+
+	ctx := context.Background()
+	ids := []uint{*gameID}
+	if *gameID == 0 {
+		if ids, err = settledGameIDs(*from, *to); err != nil {
+			fail(err)
+		}
+	}
+
+	reports := make([]*service.DtsAuditReport, 0, len(ids))
+	matched := true
+	for _, id := range ids {
+		report, err := service.AuditDtsGame(ctx, id, *ledger)
+		if err != nil {
+			fail(fmt.Errorf("game %d: %w", id, err))
+		}
+		matched = matched && report.Matched
+		reports = append(reports, report)
+	}
+
+	if *jsonOut != "-" {
+		for _, report := range reports {
+			printReport(os.Stdout, report)
+		}
+		fmt.Printf("共复算 %d 局，存在差异 %d 局\n", len(reports), countMismatched(reports))
+	}
+	if *jsonOut != "" {
+		if err := writeJSON(*jsonOut, reports); err != nil {
+			fail(err)
+		}
+	}
+
+	if !matched {
+		os.Exit(1)
+	}
+}
+
+// settledGameIDs 范围内已结算的局
+func settledGameIDs(from, to uint) ([]uint, error) {
+	query := database.DB.Model(&model.LmDtsGame{}).Where("state = ? AND id >= ?", 3, from)
+	if to > 0 {
+		query = query.Where("id <= ?", to)
+	}
+	var ids []uint
+	err := query.Order("id asc").Pluck("id", &ids).Error
+	return ids, err
+}
+
+func printReport(w io.Writer, r *service.DtsAuditReport) {
+	status := "一致"
+	if !r.Matched {
+		status = "存在差异"
+	}
+	fmt.Fprintf(w, "== 游戏 %d  [%s]\n", r.GameID, status)
+	fmt.Fprintf(w, "配置版本 %d  派奖比例 %v  杀手房间 %d", r.ConfigVersion, r.PayoutRate, r.KillerRoom)
+	if r.ComputedRoom != 0 {
+		fmt.Fprintf(w, "（种子复算 %d）", r.ComputedRoom)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "人数 %d / %d  总投注 %s / %s  杀手位 %s / %s  总奖金 %s / %s  零头 %s / %s  （库里 / 复算）\n",
+		r.Stored.TotalPeople, r.Expected.TotalPeople,
+		r.Stored.TotalAmount.String(), r.Expected.TotalAmount.String(),
+		r.Stored.TotalKillerAmount.String(), r.Expected.TotalKillerAmount.String(),
+		r.Stored.TotalBonus.String(), r.Expected.TotalBonus.String(),
+		r.Stored.PoolDust.String(), r.Expected.PoolDust.String(),
+	)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "记录\t用户\t房间\t金额\t状态\t奖金\t应入账")
+	if r.LedgerChecked {
+		fmt.Fprint(tw, "\t实际入账\t已派")
+	}
+	fmt.Fprintln(tw, "\t")
+	for _, rec := range r.Records {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%s\t%d/%d\t%s/%s\t%s",
+			rec.RecordID, rec.UserID, rec.RoomID, rec.Amount.String(),
+			rec.StoredState, rec.ExpectedState,
+			rec.StoredBonus.String(), rec.ExpectedBonus.String(),
+			rec.ExpectedPayout.String(),
+		)
+		if r.LedgerChecked {
+			fmt.Fprintf(tw, "\t%s\t%d", rec.LedgerPayout.String(), rec.Paid)
+		}
+		mark := ""
+		if !rec.Matched {
+			mark = "✗"
+		}
+		fmt.Fprintf(tw, "\t%s\n", mark)
+	}
+	_ = tw.Flush()
+
+	for _, d := range r.Diffs {
+		if d.RecordID == 0 {
+			fmt.Fprintf(w, "  差异 %s: 库里 %s，复算 %s\n", d.Field, d.Stored, d.Expected)
+		} else {
+			fmt.Fprintf(w, "  差异 记录 %d %s: 库里 %s，复算 %s\n", d.RecordID, d.Field, d.Stored, d.Expected)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+func countMismatched(reports []*service.DtsAuditReport) int {
+	n := 0
+	for _, r := range reports {
+		if !r.Matched {
+			n++
+		}
+	}
+	return n
+}
+
+func writeJSON(path string, reports []*service.DtsAuditReport) error {
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "dts-audit:", err)
+	os.Exit(2)
+}
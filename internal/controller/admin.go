@@ -0,0 +1,298 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"test/internal/game"
+	"test/internal/request"
+	"test/internal/serializer"
+	"test/internal/service"
+	"test/internal/websocket"
+	"test/pkg/response"
+	"test/pkg/util"
+)
+
+// AdminController 后台管理接口，路由上统一挂 JWTAuth + AdminAuth
+type AdminController struct{}
+
+func NewAdminController() *AdminController {
+	return &AdminController{}
+}
+
+// WalletAdjust 人工调账
+func (a *AdminController) WalletAdjust(c *gin.Context) {
+	var req request.WalletAdjustReq
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	entry, err := service.AdjustBalance(c.Request.Context(), req.UserID, req.Amount, req.Remark)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, serializer.BuildWalletTransaction(*entry))
+}
+
+// WalletReconcile 钱包对账：流水之和是否等于余额
+func (a *AdminController) WalletReconcile(c *gin.Context) {
+	report, err := service.ReconcileWallets(c.Request.Context())
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, report)
+}
+
+// BonusStats 发奖队列积压情况
+func (a *AdminController) BonusStats(c *gin.Context) {
+	stats, err := service.BonusQueueStats(c.Request.Context())
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, stats)
+}
+
+// BonusDead 分页查看死信发奖任务
+func (a *AdminController) BonusDead(c *gin.Context) {
+	var p util.PaginationReq
+	if err := c.ShouldBindQuery(&p); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	jobs, total, err := service.ListDeadBonusJobs(c.Request.Context(), p)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, serializer.BuildDataList(jobs, total, p.GetPage(), p.GetSize()))
+}
+
+// BonusReplay 重放死信发奖任务
+func (a *AdminController) BonusReplay(c *gin.Context) {
+	var req request.BonusReplayReq
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	replayed, err := service.ReplayDeadBonusJobs(c.Request.Context(), req.RecordID)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, gin.H{"replayed": replayed})
+}
+
+// GameConfig 当前生效的玩法配置
+func (a *AdminController) GameConfig(c *gin.Context) {
+	var req request.GameConfigQueryReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	cfg, err := service.GetActiveGameConfig(c.Request.Context(), req.GameType)
+	if err != nil {
+		response.Fail(c, util.NewBizErr("SystemBusy", nil))
+		return
+	}
+	response.Success(c, cfg)
+}
+
+// GameConfigHistory 玩法配置的历史版本
+func (a *AdminController) GameConfigHistory(c *gin.Context) {
+	var req request.GameConfigQueryReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	list, total, err := service.ListGameConfigs(c.Request.Context(), req.GameType, req.PaginationReq)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, serializer.BuildDataList(list, total, req.GetPage(), req.GetSize()))
+}
+
+// GameConfigUpdate 发布新版本玩法配置，从下一局开始生效
+func (a *AdminController) GameConfigUpdate(c *gin.Context) {
+	var req request.GameConfigReq
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+	if _, ok := game.Get(req.GameType); !ok {
+		response.Fail(c, util.NewBizErr("GameTypeNotFound", nil))
+		return
+	}
+
+	cfg, err := service.UpdateGameConfig(c.Request.Context(), req.GameType, req.Apply, util.GetUserID(c))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, cfg)
+}
+
+// RankRebuild 从下注记录重建排行榜
+func (a *AdminController) RankRebuild(c *gin.Context) {
+	var req request.RankRebuildReq
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	at := time.Now()
+	if req.Date != "" {
+		at, _ = time.ParseInLocation("2006-01-02", req.Date, time.Local)
+	}
+
+	bucket, err := service.RebuildLeaderboard(c.Request.Context(), req.Period, at)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, gin.H{"period": bucket.Period, "bucket": bucket.Name})
+}
+
+// WsOnline WebSocket 在线情况（集群模式下为所有节点）
+func (a *AdminController) WsOnline(c *gin.Context) {
+	stats, err := websocket.GlobalHub.OnlineStats(c.Request.Context())
+	if err != nil {
+		response.Fail(c, util.NewBizErr("SystemBusy", nil))
+		return
+	}
+	response.Success(c, stats)
+}
+
+// Discrepancies 分页查看定时对账发现的差异
+func (a *AdminController) Discrepancies(c *gin.Context) {
+	var req request.DiscrepancyListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	list, total, err := service.ListDiscrepancies(c.Request.Context(), req.Status, req.GameID, req.PaginationReq)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, serializer.BuildDataList(list, total, req.GetPage(), req.GetSize()))
+}
+
+// ReconcileRun 手动对账：指定游戏时只对账这一局，否则立即执行一次定时对账
+func (a *AdminController) ReconcileRun(c *gin.Context) {
+	var req request.ReconcileRunReq
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	if req.GameID > 0 {
+		found, err := service.ReconcileDtsGame(c.Request.Context(), req.GameID)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		response.Success(c, found)
+		return
+	}
+
+	result, err := service.RunReconcile(c.Request.Context())
+	if err != nil {
+		response.Fail(c, util.NewBizErr("SystemBusy", nil))
+		return
+	}
+	if result == nil {
+		// 其他节点正在对账
+		response.Fail(c, util.NewBizErr("ReconcileRunning", nil))
+		return
+	}
+	response.Success(c, result)
+}
+
+// DiscrepancyIgnore 人工确认差异无需处理，之后复查不再重新打开
+func (a *AdminController) DiscrepancyIgnore(c *gin.Context) {
+	var req request.DiscrepancyIgnoreReq
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	item, err := service.IgnoreDiscrepancy(c.Request.Context(), req.ID, req.Remark, util.GetUserID(c))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, item)
+}
+
+// GameVoid 作废一局：退回下注、撤回已派奖金并通知在线玩家，重复调用不会重复退款
+func (a *AdminController) GameVoid(c *gin.Context) {
+	var req request.GameVoidReq
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	result, err := service.VoidDtsGame(c.Request.Context(), req.GameID, req.Reason, util.GetUserID(c))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, result)
+}
+
+// RecoveryRun 手动执行崩溃恢复：补结算、补开局、补发奖金（启动时会自动执行一次）
+func (a *AdminController) RecoveryRun(c *gin.Context) {
+	report, err := service.RecoverDts(c.Request.Context())
+	if err != nil {
+		response.Fail(c, util.NewBizErr("SystemBusy", nil))
+		return
+	}
+	if report == nil {
+		// 结算锁被 ticker 或其他节点持有
+		response.Fail(c, util.NewBizErr("RecoveryRunning", nil))
+		return
+	}
+	response.Success(c, report)
+}
+
+// RevenueReport 按日期范围查看每日营收（下注、派奖、GGR、活跃玩家）
+func (a *AdminController) RevenueReport(c *gin.Context) {
+	var req request.RevenueReportReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	report, err := service.GetRevenueReport(c.Request.Context(), req.GameType, req.StartDate, req.EndDate)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, report)
+}
+
+// RevenueRebuild 从已结算的对局重算一段日期的营收
+func (a *AdminController) RevenueRebuild(c *gin.Context) {
+	var req request.RevenueRebuildReq
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	rows, err := service.RebuildDailyRevenue(c.Request.Context(), req.StartDate, req.EndDate)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, rows)
+}
@@ -2,17 +2,13 @@ package controller
 
 import (
 	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
-	ws "github.com/gorilla/websocket"
-	"gorm.io/gorm"
 	"net/http"
 	"strconv"
-	"test/internal/model"
 	"test/internal/request"
+	"test/internal/serializer"
 	"test/internal/service"
 	"test/internal/websocket"
-	"test/pkg/database"
 	"test/pkg/response"
 	"test/pkg/util"
 )
@@ -23,52 +19,13 @@ func NewDtsController() *DtsController {
 	return &DtsController{}
 }
 
-var upgrader = ws.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-}
-
 func (dts DtsController) Init(c *gin.Context) {
-	// 1. 获取 ID（调用封装好的工具）
-	userID := util.GetUserID(c)
-	var user model.User
-	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
-		response.Fail(c, util.NewBizErr("用户获取错误", nil))
-		return
-	}
-
-	// 2. 获取当前游戏
-	var dtsGame model.LmDtsGame
-	if err := database.DB.Order("id desc").First(&dtsGame).Error; err != nil {
-		response.Fail(c, util.NewBizErr("当前没有正在进行的游戏", nil))
-		return
-	}
-
-	req := &service.JoinGameReq{
-		GameID:   dtsGame.ID,
-		UserID:   userID,
-		RoomID:   0,   // 默认进 1 号房
-		Amount:   0.0, // 默认下注 0
-		Nickname: "New Player",
-	}
-
-	// 2. 调用逻辑，直接拿回 Redis 的数据
-	_, err := service.JoinUserList(c.Request.Context(), *req)
+	result, err := service.InitDts(c.Request.Context(), util.GetUserID(c))
 	if err != nil {
-		response.Fail(c, util.NewBizErr("操作失败", nil))
+		response.Fail(c, err)
 		return
 	}
-
-	// 3. 直接返回组合数据
-	response.Success(c, gin.H{
-		"balance": user.Amount,
-		"game_id": dtsGame.ID,
-		"user_id": userID,
-	})
-
+	response.Success(c, result)
 }
 
 func (dts DtsController) Quit(c *gin.Context) {
@@ -85,15 +42,8 @@ func (dts DtsController) Quit(c *gin.Context) {
 		return
 	}
 
-	var game model.LmDtsGame
-	if err := database.DB.Where("id = ?", gameId).First(&game).Error; err != nil {
-		response.Fail(c, util.NewBizErr("当前游戏不存在", nil))
-		return
-	}
-
-	err = service.RemoveUserList(c.Request.Context(), gameId, userID)
-	if err != nil {
-		response.Fail(c, util.NewBizErr("操作失败", nil))
+	if err := service.QuitDts(c.Request.Context(), gameId, userID); err != nil {
+		response.Fail(c, err)
 		return
 	}
 
@@ -102,150 +52,148 @@ func (dts DtsController) Quit(c *gin.Context) {
 
 func (dts DtsController) Join(c *gin.Context) {
 
-	userID := util.GetUserID(c)
-
 	var joinReq request.JoinReq
 	if err := c.ShouldBind(&joinReq); err != nil {
 		response.Fail(c, err)
 		return
 	}
 
-	if service.HasLock(c.Request.Context()) {
-		response.Fail(c, util.NewBizErr("结算中", nil))
+	if err := service.PlaceDtsBet(c.Request.Context(), joinReq.ToBet(util.GetUserID(c))); err != nil {
+		response.Fail(c, err)
 		return
 	}
 
-	// 1. 事务外：快速初筛（使用普通的 DB，不加锁）
-	var game model.LmDtsGame
-	if err := database.DB.First(&game, joinReq.GameID).Error; err != nil {
-		response.Fail(c, util.NewBizErr("游戏不存在", nil))
+	response.Success(c, gin.H{})
+
+}
+
+// Switch 换房：把本局已有的下注整体换到另一个房间，追加下注请用 Join
+func (dts DtsController) Switch(c *gin.Context) {
+	var req request.SwitchRoomReq
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, err)
 		return
 	}
 
-	if game.State == 3 {
-		response.Fail(c, util.NewBizErr("游戏已结束", nil))
+	if err := service.SwitchDtsRoom(c.Request.Context(), req.ToSwitch(util.GetUserID(c))); err != nil {
+		response.Fail(c, err)
 		return
 	}
+	response.Success(c, gin.H{})
+}
 
-	// 2. 开启事务
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
-		// 3. 事务内：悲观锁读取（核心屏障）
-		var user model.User
-
-		// 只有这行代码能保证并发安全！
-		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&user, userID).Error; err != nil {
-			return err
-		}
-
-		// 二次检查余额（以锁定的数据为准）
-		if user.Amount < joinReq.Amount {
-			return errors.New("金额不足")
-		}
-
-		// 4. 处理下注记录 (Upsert 逻辑)
-		var record model.LmDtsRecord
-		result := tx.Where("user_id = ? AND game_id = ?", userID, game.ID).First(&record)
-
-		var newTotalAmount float64
-		if result.Error == nil {
-			// 已有记录：累加金额并更新房间
-			newTotalAmount = record.Amount + joinReq.Amount
-			if err := tx.Model(&record).Updates(map[string]interface{}{
-				"room_id": joinReq.RoomID,
-				"amount":  newTotalAmount,
-			}).Error; err != nil {
-				return err
-			}
-		} else if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-
-			newTotalAmount = joinReq.Amount
-			// 无记录：创建新记录
-			if joinReq.Amount <= 0 {
-				return errors.New("金额错误")
-			}
-			newRecord := model.LmDtsRecord{
-				GameId: int64(joinReq.GameID),
-				UserId: userID,
-				RoomId: int64(joinReq.RoomID),
-				Amount: joinReq.Amount,
-			}
-			if err := tx.Create(&newRecord).Error; err != nil {
-				return err
-			}
-		} else {
-			return result.Error
-		}
-
-		// 5. 扣除用户余额
-		if err := tx.Model(&user).Update("amount", gorm.Expr("amount - ?", joinReq.Amount)).Error; err != nil {
-			return err
-		}
-
-		err := service.UpdateGame(tx, &game)
-		if err != nil {
-			return err
-		}
+// Verify 可证明公平校验：公开已结算局的种子并重新计算杀手房间
+func (dts DtsController) Verify(c *gin.Context) {
+	var req request.VerifyReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
 
-		req := &service.JoinGameReq{
-			GameID:   game.ID,
-			UserID:   userID,
-			RoomID:   joinReq.RoomID, // 默认进 1 号房
-			Amount:   newTotalAmount, // 默认下注 0
-			Nickname: "New Player",
-		}
+	result, err := service.VerifyGame(c.Request.Context(), req.GameID)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, result)
+}
 
-		err = service.AddUserList(c.Request.Context(), req)
-		if err != nil {
-			return err
-		}
+// History 历史开奖（已结算的局）
+func (dts DtsController) History(c *gin.Context) {
+	var p util.PaginationReq
+	if err := c.ShouldBindQuery(&p); err != nil {
+		response.Fail(c, err)
+		return
+	}
 
-		return nil
+	games, total, err := service.ListSettledGames(c.Request.Context(), p)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, serializer.BuildDataList(serializer.BuildDtsGames(games), total, p.GetPage(), p.GetSize()))
+}
 
-	})
+// Records 当前用户的下注记录
+func (dts DtsController) Records(c *gin.Context) {
+	var p util.PaginationReq
+	if err := c.ShouldBindQuery(&p); err != nil {
+		response.Fail(c, err)
+		return
+	}
 
+	records, total, err := service.ListUserRecords(c.Request.Context(), util.GetUserID(c), p)
 	if err != nil {
 		response.Fail(c, err)
 		return
 	}
+	response.Success(c, serializer.BuildDataList(serializer.BuildDtsRecords(records), total, p.GetPage(), p.GetSize()))
+}
 
-	response.Success(c, gin.H{})
-
+// Stats 当前用户的战绩汇总
+func (dts DtsController) Stats(c *gin.Context) {
+	stats, err := service.GetUserStats(c.Request.Context(), util.GetUserID(c))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, stats)
 }
 
-func (dts DtsController) Ws(c *gin.Context) {
+// Rank 排行榜：前 N 名 + 自己的名次
+func (dts DtsController) Rank(c *gin.Context) {
+	var req request.RankReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+	if req.Period == "" {
+		req.Period = service.RankDaily
+	}
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
 
-	uid := util.GetUserID(c)
-	fmt.Println(uid)
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	board, err := service.GetLeaderboard(c.Request.Context(), req.Period, req.Limit, util.GetUserID(c))
 	if err != nil {
+		response.Fail(c, err)
 		return
 	}
+	response.Success(c, board)
+}
 
-	client := &websocket.Client{
-		ID:   uid,
-		Send: make(chan []byte, 256),
-	}
+// WsSchema protobuf 编码（子协议 dts.protobuf）的消息定义
+func (dts DtsController) WsSchema(c *gin.Context) {
+	c.String(http.StatusOK, websocket.PushSchema)
+}
 
-	websocket.GlobalHub.Register(uid, client)
+// Spectate 未登录观众的只读推送，只有公开数据（倒计时、各房间合计、人数、杀手房间）
+func (dts DtsController) Spectate(c *gin.Context) {
+	// 观众发来的消息一律忽略
+	err := getSpectateManager().Serve(c.Writer, c.Request, c.ClientIP(), 0, nil)
+	abortWsError(c, err)
+}
 
-	// 核心修改：双向监听断开
-	go func() {
-		defer func() {
-			websocket.GlobalHub.Unregister(uid)
-			conn.Close()
-		}()
-		for {
-			// 必须读取，否则无法感知客户端主动断开
-			if _, _, err := conn.ReadMessage(); err != nil {
-				return
-			}
-		}
-	}()
+func (dts DtsController) Ws(c *gin.Context) {
+	uid := util.GetUserID(c)
+	// Serve 阻塞到连接结束，期间 gin.Context 一直有效，指令的错误信息按连接时的语言翻译
+	err := getDtsWsManager().Serve(c.Writer, c.Request, c.ClientIP(), uid, func(client *websocket.Client, msg []byte) []byte {
+		return handleWsMessage(c, client.ID, msg)
+	})
+	abortWsError(c, err)
+}
 
-	for msg := range client.Send {
-		if err := conn.WriteMessage(ws.TextMessage, msg); err != nil {
-			break
-		}
+// abortWsError 连接没有建立起来时回复 HTTP 错误；连接建立之后的错误已经通过关闭码告诉客户端，这里不再处理
+func abortWsError(c *gin.Context, err error) {
+	var upgradeErr *websocket.UpgradeError
+	switch {
+	case err == nil:
+	case errors.Is(err, websocket.ErrShuttingDown):
+		// 停机中的节点不再接新连接，客户端重试时由负载均衡分到其他节点
+		c.AbortWithStatus(http.StatusServiceUnavailable)
+	case errors.Is(err, websocket.ErrTooManyConnections), errors.Is(err, websocket.ErrTooManyFromIP):
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, response.FailResponse(c, util.NewBizErr("TooManyConnections", nil)))
+	case errors.As(err, &upgradeErr):
+		c.AbortWithStatusJSON(upgradeErr.Status, response.FailResponse(c, util.NewBizErr("WsUpgradeFailed", nil)))
 	}
-
 }
@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"test/internal/event"
+	"test/internal/game"
+	"test/internal/request"
+	"test/internal/service"
+	"test/internal/websocket"
+	"test/pkg/config"
+	"test/pkg/response"
+	"test/pkg/util"
+)
+
+var (
+	dtsWsOnce    sync.Once
+	dtsWsManager *websocket.Manager
+)
+
+// getDtsWsManager 大逃杀推送连接，连接建立后补发一次全量，之后只收增量
+// 断线重连时带上 ?last_seq=N 只补发缺失的消息
+// 同一用户多个会话的处理方式按配置（默认允许手机 + 浏览器同时打开），配置加载后才能创建
+func getDtsWsManager() *websocket.Manager {
+	dtsWsOnce.Do(func() {
+		m := websocket.NewManager(websocket.GlobalHub)
+		policy, err := websocket.ParseSessionPolicy(config.Conf.Websocket.SessionPolicy)
+		if err != nil {
+			fmt.Printf("WebSocket 会话策略配置错误，按 allow_many 处理: %v\n", err)
+		}
+		m.SessionPolicy = policy
+		m.MaxSessions = config.Conf.Websocket.MaxSessions
+		m.OnConnect = func(client *websocket.Client) {
+			data := map[string]interface{}{}
+			if lastSeq, err := strconv.ParseInt(client.Query.Get("last_seq"), 10, 64); err == nil && lastSeq >= 0 {
+				data["last_seq"] = lastSeq
+			}
+			event.Publish(event.Event{Type: event.UserConnected, UserID: client.ID, SessionID: client.SessionID, Data: data})
+			go realityCheck(client)
+		}
+		dtsWsManager = m
+	})
+	return dtsWsManager
+}
+
+// realityCheckPoll 重新读取提醒间隔的周期，连接期间修改的设置最多这么久之后生效
+const realityCheckPoll = time.Minute
+
+// realityCheck 连接期间按用户设置的间隔推送游戏时长提醒，连接断开后退出
+// 间隔每次都重新读取：玩家在连接期间调短、关闭或者重新开启提醒都能生效
+func realityCheck(client *websocket.Client) {
+	ticker := time.NewTicker(realityCheckPoll)
+	defer ticker.Stop()
+	last := client.ConnectedAt
+	for {
+		select {
+		case <-ticker.C:
+			interval := service.RealityCheckInterval(context.Background(), client.ID)
+			if interval <= 0 || time.Since(last) < interval {
+				continue
+			}
+			msg, err := service.RealityCheckMessage(context.Background(), client.ID, client.ConnectedAt)
+			if err != nil {
+				continue
+			}
+			last = time.Now()
+			if data, err := json.Marshal(msg); err == nil {
+				client.TrySend(data)
+			}
+		case <-client.Done():
+			return
+		}
+	}
+}
+
+// wsHandler 一种 WebSocket 指令的处理函数，返回值作为应答的 data
+type wsHandler func(c *gin.Context, userID int64, payload json.RawMessage) (interface{}, error)
+
+// dtsWsHandlers 和 HTTP 接口调用同一套 service，行为保持一致
+var dtsWsHandlers = map[string]wsHandler{
+	websocket.CmdInit: func(c *gin.Context, userID int64, payload json.RawMessage) (interface{}, error) {
+		return service.InitDts(c.Request.Context(), userID)
+	},
+	websocket.CmdJoin: func(c *gin.Context, userID int64, payload json.RawMessage) (interface{}, error) {
+		var req request.JoinReq
+		if err := bindWsPayload(payload, &req); err != nil {
+			return nil, err
+		}
+		return gin.H{}, service.PlaceDtsBet(c.Request.Context(), req.ToBet(userID))
+	},
+	websocket.CmdSwitch: func(c *gin.Context, userID int64, payload json.RawMessage) (interface{}, error) {
+		var req request.SwitchRoomReq
+		if err := bindWsPayload(payload, &req); err != nil {
+			return nil, err
+		}
+		return gin.H{}, service.SwitchDtsRoom(c.Request.Context(), req.ToSwitch(userID))
+	},
+	websocket.CmdQuit: func(c *gin.Context, userID int64, payload json.RawMessage) (interface{}, error) {
+		var req request.QuitReq
+		if err := bindWsPayload(payload, &req); err != nil {
+			return nil, err
+		}
+		return gin.H{}, service.QuitDts(c.Request.Context(), req.GameID, userID)
+	},
+	websocket.CmdState: func(c *gin.Context, userID int64, payload json.RawMessage) (interface{}, error) {
+		// 与推送的字段名一致：<玩法>_data 为公共部分，<玩法>_user 为自己的数据
+		state := gin.H{}
+		for _, e := range game.All() {
+			data, err := e.StatePayload(c.Request.Context(), []int64{userID})
+			if err != nil {
+				return nil, util.NewBizErr("SystemBusy", nil)
+			}
+			if data == nil {
+				continue
+			}
+			state[e.Name()+"_data"] = data.Broadcast
+			if user, ok := data.Users[userID]; ok {
+				state[e.Name()+"_user"] = user
+			}
+		}
+		return state, nil
+	},
+}
+
+// bindWsPayload 解析并校验指令参数，校验规则与 HTTP 接口共用 binding 标签
+func bindWsPayload(payload json.RawMessage, obj interface{}) error {
+	if len(payload) == 0 {
+		payload = json.RawMessage("{}")
+	}
+	if err := json.Unmarshal(payload, obj); err != nil {
+		return util.NewBizErr("InvalidJSON", nil)
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// handleWsMessage 处理一条客户端消息并返回应答，错误信息按连接时的语言翻译
+func handleWsMessage(c *gin.Context, userID int64, msg []byte) []byte {
+	var req websocket.Request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return marshalReply(websocket.Reply{Response: response.FailResponse(c, util.NewBizErr("InvalidJSON", nil))})
+	}
+
+	reply := websocket.Reply{ID: req.ID, Type: req.Type}
+	handler, ok := dtsWsHandlers[req.Type]
+	if !ok {
+		reply.Response = response.FailResponse(c, util.NewBizErr("WsUnknownCommand", map[string]interface{}{"Type": req.Type}))
+		return marshalReply(reply)
+	}
+
+	data, err := handler(c, userID, req.Payload)
+	if err != nil {
+		reply.Response = response.FailResponse(c, err)
+	} else {
+		reply.Response = response.SuccessResponse(c, data)
+	}
+	return marshalReply(reply)
+}
+
+func marshalReply(reply websocket.Reply) []byte {
+	data, _ := json.Marshal(reply)
+	return data
+}
+
+var (
+	spectateOnce    sync.Once
+	spectateManager *websocket.Manager
+)
+
+// getSpectateManager 观众连接：只读、消息上限很小、有独立的连接数限制（配置加载后才能创建）
+func getSpectateManager() *websocket.Manager {
+	spectateOnce.Do(func() {
+		m := websocket.NewManager(websocket.SpectatorHub)
+		m.MaxMessageSize = 512
+		m.SendBuffer = 16
+		// 观众的用户 ID 都是 0，只能允许多个连接，否则观众之间会互相挤掉
+		m.SessionPolicy = websocket.SessionAllowMany
+		m.MaxSessions = 0
+		m.MaxConnections = config.Conf.Spectate.MaxConnections
+		m.MaxPerIP = config.Conf.Spectate.MaxPerIp
+		m.OnConnect = func(client *websocket.Client) {
+			event.Publish(event.Event{Type: event.SpectatorJoined, SessionID: client.SessionID})
+		}
+		spectateManager = m
+	})
+	return spectateManager
+}
+
+// ShutdownWebsockets 停机前关闭所有 WebSocket 连接（客户端收到 CloseGoingAway 后可以换节点重连）
+func ShutdownWebsockets(ctx context.Context) error {
+	var firstErr error
+	for _, m := range []*websocket.Manager{getDtsWsManager(), getSpectateManager()} {
+		if err := m.Shutdown(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
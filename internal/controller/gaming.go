@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"test/internal/request"
+	"test/internal/serializer"
+	"test/internal/service"
+	"test/pkg/response"
+	"test/pkg/util"
+)
+
+// GamingController 负责任博彩：玩家自己设置限制、冷静期和自我排除
+type GamingController struct{}
+
+func NewGamingController() *GamingController {
+	return &GamingController{}
+}
+
+// Limits 当前的限制、待生效的修改和各周期已用额度
+func (g *GamingController) Limits(c *gin.Context) {
+	view, err := service.GetGamingLimits(c.Request.Context(), util.GetUserID(c))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, view)
+}
+
+// UpdateLimits 修改下注 / 亏损上限和游戏时长提醒
+func (g *GamingController) UpdateLimits(c *gin.Context) {
+	var req request.GamingLimitReq
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	view, err := service.UpdateGamingLimits(c.Request.Context(), util.GetUserID(c), req.Values(), req.RealityCheckMinutes, c.ClientIP())
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, view)
+}
+
+// CoolOff 开启冷静期
+func (g *GamingController) CoolOff(c *gin.Context) {
+	var req request.CoolOffReq
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	view, err := service.SetCoolOff(c.Request.Context(), util.GetUserID(c), req.Days, c.ClientIP())
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, view)
+}
+
+// SelfExclude 自我排除
+func (g *GamingController) SelfExclude(c *gin.Context) {
+	var req request.SelfExcludeReq
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	view, err := service.SelfExclude(c.Request.Context(), util.GetUserID(c), *req.Days, c.ClientIP())
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, view)
+}
+
+// Logs 限制的修改记录
+func (g *GamingController) Logs(c *gin.Context) {
+	var p util.PaginationReq
+	if err := c.ShouldBindQuery(&p); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	list, total, err := service.ListGamingLimitLogs(c.Request.Context(), util.GetUserID(c), p)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, serializer.BuildDataList(list, total, p.GetPage(), p.GetSize()))
+}
@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"test/internal/request"
+	"test/internal/serializer"
+	"test/internal/service"
+	"test/pkg/response"
+	"test/pkg/util"
+)
+
+type WalletController struct{}
+
+func NewWalletController() *WalletController {
+	return &WalletController{}
+}
+
+// Transactions 当前用户的钱包流水
+// @Summary 钱包流水
+// @Description 分页获取当前用户的余额变动记录，可按类型过滤
+// @Tags Wallet
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param type query string false "类型 bet/payout/refund/adjustment/switch_fee/reversal"
+// @Success 200 {object} response.Response{data=serializer.WalletTransactionDataList} "成功返回"
+// @Router /user/transactions [get]
+func (w *WalletController) Transactions(c *gin.Context) {
+	var req request.WalletListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	list, total, err := service.ListWalletTransactions(c.Request.Context(), util.GetUserID(c), req.Type, req.PaginationReq)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	data := serializer.BuildDataList(serializer.BuildWalletTransactions(list), total, req.GetPage(), req.GetSize())
+	response.Success(c, data)
+}
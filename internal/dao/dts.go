@@ -0,0 +1,73 @@
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"test/internal/model"
+	"test/pkg/money"
+)
+
+type DtsDao struct {
+	db *gorm.DB
+}
+
+func NewDtsDao(db *gorm.DB) *DtsDao {
+	return &DtsDao{db: db}
+}
+
+// ListSettledGames 分页查询已结算的局（新的在前）
+func (dao *DtsDao) ListSettledGames(ctx context.Context, offset, limit int) ([]model.LmDtsGame, int64, error) {
+	var games []model.LmDtsGame
+	var total int64
+
+	db := dao.db.WithContext(ctx).Model(&model.LmDtsGame{}).Where("state = ?", 3)
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := db.Order("id desc").Offset(offset).Limit(limit).Find(&games).Error
+	return games, total, err
+}
+
+// ListUserRecords 分页查询用户的下注记录（新的在前）
+func (dao *DtsDao) ListUserRecords(ctx context.Context, userID int64, offset, limit int) ([]model.LmDtsRecord, int64, error) {
+	var records []model.LmDtsRecord
+	var total int64
+
+	db := dao.db.WithContext(ctx).Model(&model.LmDtsRecord{}).Where("user_id = ?", userID)
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := db.Order("id desc").Offset(offset).Limit(limit).Find(&records).Error
+	return records, total, err
+}
+
+// UserRecordStats 用户下注的汇总数据
+type UserRecordStats struct {
+	Rounds     int64       // 参与局数
+	Wins       int64       // 胜局（未被杀）
+	Losses     int64       // 负局（被杀）
+	TotalBet   money.Money // 累计下注
+	TotalBonus money.Money // 累计奖金（不含退回的本金）
+	TotalLost  money.Money // 被杀输掉的本金
+}
+
+// GetUserRecordStats 一条 SQL 汇总用户的全部下注记录
+func (dao *DtsDao) GetUserRecordStats(ctx context.Context, userID int64) (*UserRecordStats, error) {
+	var stats UserRecordStats
+	err := dao.db.WithContext(ctx).Model(&model.LmDtsRecord{}).
+		Select(`COUNT(*) as rounds,
+			COALESCE(SUM(CASE WHEN state = 1 THEN 1 ELSE 0 END), 0) as wins,
+			COALESCE(SUM(CASE WHEN state = 2 THEN 1 ELSE 0 END), 0) as losses,
+			COALESCE(SUM(amount), 0) as total_bet,
+			COALESCE(SUM(CASE WHEN state = 1 THEN bonus ELSE 0 END), 0) as total_bonus,
+			COALESCE(SUM(CASE WHEN state = 2 THEN amount ELSE 0 END), 0) as total_lost`).
+		Where("user_id = ?", userID).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
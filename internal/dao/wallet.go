@@ -0,0 +1,69 @@
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"test/internal/model"
+	"test/pkg/money"
+)
+
+type WalletDao struct {
+	db *gorm.DB
+}
+
+func NewWalletDao(db *gorm.DB) *WalletDao {
+	return &WalletDao{db: db}
+}
+
+// ListByUser 分页查询用户流水，txType 为空时不过滤类型
+func (dao *WalletDao) ListByUser(ctx context.Context, userID int64, txType string, offset, limit int) ([]model.WalletTransaction, int64, error) {
+	var list []model.WalletTransaction
+	var total int64
+
+	db := dao.db.WithContext(ctx).Model(&model.WalletTransaction{}).Where("user_id = ?", userID)
+	if txType != "" {
+		db = db.Where("type = ?", txType)
+	}
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := db.Order("id desc").Offset(offset).Limit(limit).Find(&list).Error
+	return list, total, err
+}
+
+// UserLedgerSum 单个账户的分录合计
+type UserLedgerSum struct {
+	UserId int64
+	Total  money.Money
+}
+
+// SumByUser 按用户汇总分录（不含平台账户）
+func (dao *WalletDao) SumByUser(ctx context.Context) ([]UserLedgerSum, error) {
+	var sums []UserLedgerSum
+	err := dao.db.WithContext(ctx).Model(&model.WalletTransaction{}).
+		Select("user_id, SUM(amount) as total").
+		Where("user_id <> 0").
+		Group("user_id").
+		Scan(&sums).Error
+	return sums, err
+}
+
+// SumAll 整本账的分录合计
+func (dao *WalletDao) SumAll(ctx context.Context) (money.Money, error) {
+	var total money.Money
+	err := dao.db.WithContext(ctx).Model(&model.WalletTransaction{}).
+		Select("SUM(amount)").Row().Scan(&total)
+	return total, err
+}
+
+// UnbalancedTxNos 借贷不平的交易号
+func (dao *WalletDao) UnbalancedTxNos(ctx context.Context) ([]string, error) {
+	var txNos []string
+	err := dao.db.WithContext(ctx).Model(&model.WalletTransaction{}).
+		Group("tx_no").
+		Having("SUM(amount) <> 0").
+		Pluck("tx_no", &txNos).Error
+	return txNos, err
+}
@@ -0,0 +1,88 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 领域事件类型
+const (
+	BetPlaced        = "bet_placed"        // 玩家下注（含追加）
+	RoomSwitched     = "room_switched"     // 玩家把下注换到另一个房间
+	CountdownStarted = "countdown_started" // 人数达标，开始倒计时（封盘前）
+	GameSettled      = "game_settled"      // 一局结算完成
+	GameVoided       = "game_voided"       // 一局被后台作废，下注已全部退回
+	GameCreated      = "game_created"      // 新的一局开始
+	UserConnected    = "user_connected"    // 用户建立了 WebSocket 连接，需要补发一次全量数据
+	SpectatorJoined  = "spectator_joined"  // 未登录观众建立了连接，需要补发一次公开数据
+)
+
+// Event 一个领域事件，Data 由发布方按事件类型约定内容
+type Event struct {
+	Type      string
+	GameType  int
+	GameID    uint
+	UserID    int64
+	SessionID string // 用户的某一个 WebSocket 会话，只有连接相关的事件才有
+	Data      map[string]interface{}
+	At        int64
+}
+
+// Handler 事件处理函数
+type Handler func(ctx context.Context, e Event)
+
+var (
+	mu       sync.RWMutex
+	handlers []Handler
+
+	// 发布方（下注接口、结算流程）不能被推送拖慢，事件先进缓冲通道，由 Run 异步分发
+	queue = make(chan Event, 1024)
+)
+
+// Subscribe 订阅所有事件，在 Run 之前调用
+func Subscribe(h Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers = append(handlers, h)
+}
+
+// Publish 发布事件，不阻塞；缓冲满了说明消费跟不上，丢弃并打印日志（下一次全量快照会兜底）
+func Publish(e Event) {
+	if e.At == 0 {
+		e.At = time.Now().Unix()
+	}
+	select {
+	case queue <- e:
+	default:
+		fmt.Printf("事件队列已满，丢弃事件: %s game_id=%d\n", e.Type, e.GameID)
+	}
+}
+
+// Run 按发布顺序把事件分发给订阅者，直到 ctx 结束
+func Run(ctx context.Context) {
+	for {
+		select {
+		case e := <-queue:
+			mu.RLock()
+			list := handlers
+			mu.RUnlock()
+			for _, h := range list {
+				dispatch(ctx, h, e)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// dispatch 单个订阅者 panic 不能影响其他订阅者和后续事件
+func dispatch(ctx context.Context, h Handler, e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("事件处理 panic: %s %v\n", e.Type, r)
+		}
+	}()
+	h(ctx, e)
+}
@@ -0,0 +1,99 @@
+package game
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+	"test/internal/event"
+	"test/pkg/money"
+)
+
+// 游戏类型编号，推送给前端的 game_type
+const (
+	TypeDts = 1 // 大逃杀
+)
+
+// Bet 一次下注请求，各玩法自行解释 RoomID 的含义（房间、格子、号码…）
+type Bet struct {
+	GameID uint
+	UserID int64
+	RoomID int
+	Amount money.Money
+}
+
+// Engine 一种游戏玩法
+// process.Handle 的 ticker 只认识这个接口：找出到期的局 -> 结算 -> 开下一局，
+// 推送任务也只通过 StatePayload / EventPayload 取数据，新增玩法不需要再复制 controller/service/process 三件套
+type Engine interface {
+	// Type 游戏类型编号
+	Type() int
+	// Name 英文短名，用于锁、缓存 Key 和推送字段前缀（例如 dts -> dts_data）
+	Name() string
+
+	// Init 启动时调用，保证至少有一局可以下注
+	Init(ctx context.Context) error
+	// CreateRound 开新的一局，prevOutcome 为上一局的结果（首局为 0）
+	CreateRound(ctx context.Context, prevOutcome int64) error
+	// DueRounds 已经到期、等待结算的局
+	DueRounds(ctx context.Context) ([]uint, error)
+	// ExpireRounds 处理迟迟凑不够人数的局（退款或强制开始），在结算之前调用
+	ExpireRounds(ctx context.Context) error
+
+	// ValidateBet 下注前校验，tx 为下注所在的事务
+	ValidateBet(ctx context.Context, tx *gorm.DB, bet Bet) error
+	// SelectOutcome 计算一局的结果（例如大逃杀的杀手房间），不落库
+	SelectOutcome(ctx context.Context, roundID uint) (int64, error)
+	// Settle 结算一局并返回结果，结算失败返回 error，当局保持原状等待下次 tick
+	Settle(ctx context.Context, roundID uint) (int64, error)
+
+	// StatePayload 当前局面的全量快照：公共部分 + 指定用户的个人数据
+	StatePayload(ctx context.Context, userIDs []int64) (*Payload, error)
+	// EventPayload 把领域事件转换成增量推送，返回 nil 表示本玩法不关心这个事件
+	EventPayload(ctx context.Context, e event.Event) (*Payload, error)
+	// SpectatorPayload 给未登录观众看的公开数据，不能包含任何用户的信息
+	SpectatorPayload(ctx context.Context) (interface{}, error)
+}
+
+// Payload 一次推送的内容
+// Broadcast 发给所有在线用户；Users 额外单独发给对应的用户（个人余额、输赢等）
+type Payload struct {
+	Broadcast interface{}
+	Users     map[int64]interface{}
+}
+
+var (
+	mu      sync.RWMutex
+	engines = make(map[int]Engine)
+)
+
+// Register 注册玩法，通常在实现所在包的 init() 里调用，重复注册直接 panic
+func Register(e Engine) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, exists := engines[e.Type()]; exists {
+		panic("game: engine already registered: " + e.Name())
+	}
+	engines[e.Type()] = e
+}
+
+// Get 按类型获取玩法
+func Get(gameType int) (Engine, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	e, ok := engines[gameType]
+	return e, ok
+}
+
+// All 所有已注册的玩法，按类型编号排序，保证 ticker 的处理顺序稳定
+func All() []Engine {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]Engine, 0, len(engines))
+	for _, e := range engines {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Type() < list[j].Type() })
+	return list
+}
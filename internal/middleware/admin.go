@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"test/pkg/config"
+	"test/pkg/util"
+)
+
+// AdminAuth 后台权限校验，必须挂在 JWTAuth 之后（依赖上下文里的 userID）
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := util.GetUserID(c)
+		for _, id := range config.Conf.Admin.UserIds {
+			if userID != 0 && id == userID {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "无权访问"})
+		c.Abort()
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"test/pkg/redis"
+	"test/pkg/response"
+	"test/pkg/util"
+)
+
+// RateLimit 按客户端 IP 限流（Redis 固定窗口，多实例共享计数）
+// limit 返回当前的上限，允许配置热更新；上限 <= 0 时不限流；Redis 异常时放行，不影响正常访问
+func RateLimit(name string, window time.Duration, limit func() int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		max := limit()
+		if max <= 0 {
+			c.Next()
+			return
+		}
+
+		bucket := time.Now().Unix() / int64(window.Seconds())
+		key := fmt.Sprintf("rate_limit:%s:%s:%d", name, c.ClientIP(), bucket)
+		count, err := redis.RedisClient.Incr(c.Request.Context(), key).Result()
+		if err == nil && count == 1 {
+			redis.RedisClient.Expire(c.Request.Context(), key, window)
+		}
+		if err == nil && count > int64(max) {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, response.FailResponse(c, util.NewBizErr("TooManyRequests", nil)))
+			return
+		}
+		c.Next()
+	}
+}
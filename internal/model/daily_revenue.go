@@ -0,0 +1,25 @@
+package model
+
+import (
+	"gorm.io/gorm"
+	"test/pkg/money"
+)
+
+// DailyRevenue 每天每种游戏的营收汇总，按结算时间归属到自然日
+// 由已结算的对局重新汇总得到，可以随时重算；作废的局不计入
+type DailyRevenue struct {
+	gorm.Model
+	Date          string      `json:"date" gorm:"type:char(10);uniqueIndex:idx_daily_revenue,priority:1"` // 日期：2006-01-02
+	GameType      int         `json:"game_type" gorm:"uniqueIndex:idx_daily_revenue,priority:2"`          // 游戏类型：1:大逃杀
+	Rounds        int64       `json:"rounds" gorm:"not null;default:0"`                                   // 结算局数
+	Bets          money.Money `json:"bets" gorm:"type:decimal(16,2);not null;default:0"`                  // 总下注额
+	Payouts       money.Money `json:"payouts" gorm:"type:decimal(16,2);not null;default:0"`               // 总派奖（胜者的本金 + 奖金）
+	SwitchFees    money.Money `json:"switch_fees" gorm:"type:decimal(16,2);not null;default:0"`           // 换房手续费
+	Ggr           money.Money `json:"ggr" gorm:"type:decimal(16,2);not null;default:0"`                   // 毛博彩收入：总下注额 - 总派奖 + 换房手续费
+	ActivePlayers int64       `json:"active_players" gorm:"not null;default:0"`                           // 有下注的玩家数（去重）
+}
+
+// TableName 表名称
+func (*DailyRevenue) TableName() string {
+	return "daily_revenues"
+}
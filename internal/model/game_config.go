@@ -0,0 +1,40 @@
+package model
+
+import (
+	"gorm.io/gorm"
+	"test/pkg/money"
+)
+
+// GameConfig 玩法配置（版本化）
+// 每次修改都新增一行并把 Version + 1，旧版本永不修改；
+// 每一局游戏记录创建时的 ConfigVersion，历史局可以按当时的规则复算
+type GameConfig struct {
+	gorm.Model
+	GameType   int     `json:"game_type" gorm:"uniqueIndex:idx_game_config_version,priority:1"` // 游戏类型：1:大逃杀
+	Version    int     `json:"version" gorm:"uniqueIndex:idx_game_config_version,priority:2"`   // 配置版本号，从 1 开始递增
+	MaxPeople  int     `json:"max_people" gorm:"type:int;not null"`                             // 开始倒计时所需的下注人数
+	Duration   int     `json:"duration" gorm:"type:int;not null"`                               // 倒计时时长（秒）
+	RoomCount  int     `json:"room_count" gorm:"type:int;not null"`                             // 房间数量，房间号为 1..RoomCount
+	PayoutRate float64 `json:"payout_rate" gorm:"type:decimal(5,4);not null"`                   // 派奖比例：杀手房间总额 × PayoutRate 分给胜者，始终等于 1 - RakeRate
+	RakeRate   float64 `json:"rake_rate" gorm:"type:decimal(5,4);not null;default:0"`           // 平台抽水比例：杀手房间总额 × RakeRate 归平台
+	// 下注限制，0 表示不限制；房间号范围由 RoomCount 决定
+	MinBet          money.Money `json:"min_bet" gorm:"type:decimal(16,2);not null;default:0"`        // 单次下注最小金额
+	MaxBet          money.Money `json:"max_bet" gorm:"type:decimal(16,2);not null;default:0"`        // 单次下注最大金额
+	MaxUserTotal    money.Money `json:"max_user_total" gorm:"type:decimal(16,2);not null;default:0"` // 每个玩家每局累计下注上限
+	MaxRoomTotal    money.Money `json:"max_room_total" gorm:"type:decimal(16,2);not null;default:0"` // 每个房间每局的下注总额上限
+	MaxBetsPerRound int         `json:"max_bets_per_round" gorm:"type:int;not null;default:0"`       // 每个玩家每局最多下注次数（含追加）
+	// 换房规则
+	MaxSwitches       int         `json:"max_switches" gorm:"type:int;not null;default:0"`         // 每局最多换房次数，0 表示不限制，-1 表示禁止换房
+	SwitchLockSeconds int         `json:"switch_lock_seconds" gorm:"type:int;not null;default:0"`  // 倒计时最后多少秒禁止换房，0 表示不锁定
+	SwitchFee         money.Money `json:"switch_fee" gorm:"type:decimal(16,2);not null;default:0"` // 每次换房收取的手续费，0 表示免费
+	// 人数迟迟不够时的处理
+	MaxWaitSeconds    int    `json:"max_wait_seconds" gorm:"type:int;not null;default:0"`                   // 从第一笔下注起最多等待多少秒，0 表示一直等
+	WaitTimeoutPolicy string `json:"wait_timeout_policy" gorm:"type:varchar(16);not null;default:'refund'"` // 等待超时后的处理：refund 退款并开下一局 / start 直接开始倒计时
+	Remark            string `json:"remark" gorm:"type:varchar(255)"`                                       // 修改说明
+	Operator          int64  `json:"operator"`                                                              // 修改人（管理员用户 ID），0 表示系统默认
+}
+
+// TableName 表名称
+func (*GameConfig) TableName() string {
+	return "game_configs"
+}
@@ -1,19 +1,33 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+	"test/pkg/money"
+)
 
 // LmDtsGame undefined
 type LmDtsGame struct {
 	gorm.Model
-	State             int8    `json:"state" gorm:"state"`                             // 游戏状态：1:等待加入/进行中，2:倒计时开始（封盘），3:已结束（结算完成）
-	KillerRoom        int64   `json:"killer_room" gorm:"killer_room"`                 // 杀手房间：本局被选中的“死亡房间”编号
-	PreKillerRoom     int64   `json:"pre_killer_room" gorm:"pre_killer_room"`         // 上局杀手房间：前一局的死亡房间编号
-	TotalPeople       int64   `json:"total_people" gorm:"total_people"`               // 总人数：参与本局游戏的总玩家数
-	TotalAmount       float64 `json:"total_amount" gorm:"total_amount"`               // 总下注额：本局所有玩家下注的总金额
-	TotalBonus        float64 `json:"total_bonus" gorm:"total_bonus"`                 // 总奖金：本局派发出的总奖励
-	TotalKillerAmount float64 `json:"total_killer_amount" gorm:"total_killer_amount"` // 杀手位总额：在死亡房间内的玩家下注总额
-	StartTime         int64   `json:"start_time" gorm:"start_time"`                   // 开始时间：Unix 时间戳
-	EndTime           int64   `json:"end_time" gorm:"end_time"`                       // 结束时间：倒计时结束的时间戳
+	State             int8        `json:"state" gorm:"state"`                                               // 游戏状态：1:等待加入/进行中，2:倒计时开始（封盘），3:已结束（结算完成），4:已作废（下注全部退回）
+	KillerRoom        int64       `json:"killer_room" gorm:"killer_room"`                                   // 杀手房间：本局被选中的“死亡房间”编号
+	PreKillerRoom     int64       `json:"pre_killer_room" gorm:"pre_killer_room"`                           // 上局杀手房间：前一局的死亡房间编号
+	TotalPeople       int64       `json:"total_people" gorm:"total_people"`                                 // 总人数：参与本局游戏的总玩家数
+	TotalAmount       money.Money `json:"total_amount" gorm:"type:decimal(16,2);not null;default:0"`        // 总下注额：本局所有玩家下注的总金额
+	TotalBonus        money.Money `json:"total_bonus" gorm:"type:decimal(16,2);not null;default:0"`         // 总奖金：本局派发出的总奖励
+	TotalKillerAmount money.Money `json:"total_killer_amount" gorm:"type:decimal(16,2);not null;default:0"` // 杀手位总额：在死亡房间内的玩家下注总额
+	PoolDust          money.Money `json:"pool_dust" gorm:"type:decimal(16,2);not null;default:0"`           // 奖池零头：按比例拆分奖金时每人向下取整到分，舍去的零头留在平台账户
+	RakeRate          float64     `json:"rake_rate" gorm:"type:decimal(5,4);not null;default:0"`            // 抽水比例：结算时使用的配置
+	RakeAmount        money.Money `json:"rake_amount" gorm:"type:decimal(16,2);not null;default:0"`         // 抽水金额：杀手位总额 - 奖池，没有胜者时为杀手位总额
+	HouseRevenue      money.Money `json:"house_revenue" gorm:"type:decimal(16,2);not null;default:0"`       // 平台收入：抽水 + 奖池零头，等于总下注额 - 总派奖
+	StartTime         int64       `json:"start_time" gorm:"start_time"`                                     // 开始时间：Unix 时间戳
+	EndTime           int64       `json:"end_time" gorm:"index"`                                            // 结束时间：倒计时结束的时间戳，结算后为实际结算时间
+	ServerSeedHash    string      `json:"server_seed_hash" gorm:"type:char(64)"`                            // 服务端种子哈希：开局即公开的承诺值
+	ServerSeed        string      `json:"-" gorm:"type:char(64)"`                                           // 服务端种子：结算后才通过校验接口公开
+	ClientSeed        string      `json:"client_seed" gorm:"type:char(64)"`                                 // 公共种子：由本局所有玩家的客户端种子合成
+	ConfigVersion     int         `json:"config_version" gorm:"type:int;default:0"`                         // 配置版本：开局时的 GameConfig.Version，0 表示默认配置
+	VoidReason        string      `json:"void_reason" gorm:"type:varchar(255)"`                             // 作废原因
+	VoidOperator      int64       `json:"void_operator"`                                                    // 作废操作人（管理员用户 ID）
+	VoidedAt          int64       `json:"voided_at"`                                                        // 作废时间
 	// 在 Record 表里找 GameId，它引用了我表里的 ID
 	Records []LmDtsRecord `gorm:"foreignKey:GameId;references:ID"`
 }
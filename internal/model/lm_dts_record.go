@@ -1,6 +1,9 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+	"test/pkg/money"
+)
 
 // LmDtsRecord undefined
 type LmDtsRecord struct {
@@ -8,14 +11,17 @@ type LmDtsRecord struct {
 	GameId int64     `json:"game_id" gorm:"game_id"`
 	Game   LmDtsGame `json:"game" gorm:"foreignKey:GameId;references:ID"`
 
-	UserId      int64   `json:"user_id" gorm:"user_id"`
-	RoomId      int64   `json:"room_id" gorm:"room_id"`           // 房间 ID：玩家选择进入的房间（1-5，对应金木水火土）
-	Amount      float64 `json:"amount" gorm:"amount"`             // 下注金额
-	PaymentType string  `json:"payment_type" gorm:"payment_type"` // 支付方式：例如余额、等
-	State       int8    `json:"state" gorm:"state"`               // 状态：0:等待 1:胜 2:负 结算状态：0:等待中，1:胜利（未被杀），2:失败（被杀）
-	KillerRoom  int64   `json:"killer_room" gorm:"killer_room"`   // 结算时的杀手房间
-	Bonus       float64 `json:"bonus" gorm:"bonus"`               // 获得奖金
-	Num         int8    `json:"num" gorm:"num"`                   //倍数/编号
+	UserId      int64       `json:"user_id" gorm:"user_id"`
+	RoomId      int64       `json:"room_id" gorm:"room_id"`                              // 房间 ID：玩家选择进入的房间（1-5，对应金木水火土）
+	Amount      money.Money `json:"amount" gorm:"type:decimal(16,2);not null;default:0"` // 下注金额
+	PaymentType string      `json:"payment_type" gorm:"payment_type"`                    // 支付方式：例如余额、等
+	State       int8        `json:"state" gorm:"state"`                                  // 状态：0:等待 1:胜 2:负 3:作废 结算状态：0:等待中，1:胜利（未被杀），2:失败（被杀），3:对局作废（已退款）
+	KillerRoom  int64       `json:"killer_room" gorm:"killer_room"`                      // 结算时的杀手房间
+	Bonus       money.Money `json:"bonus" gorm:"type:decimal(16,2);not null;default:0"`  // 获得奖金
+	Num         int8        `json:"num" gorm:"num"`                                      //倍数/编号
+	ClientSeed  string      `json:"client_seed" gorm:"type:varchar(64)"`                 // 客户端种子：玩家下注时提交，参与杀手房间的计算
+	Paid        int8        `json:"paid" gorm:"type:tinyint;default:0"`                  // 派奖状态：0:未派 1:已派（发奖任务按此幂等）
+	SwitchCount int         `json:"switch_count" gorm:"type:int;not null;default:0"`     // 本局换房次数
 }
 
 // TableName 表名称
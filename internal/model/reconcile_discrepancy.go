@@ -0,0 +1,24 @@
+package model
+
+import "gorm.io/gorm"
+
+// ReconcileDiscrepancy 定时对账发现的差异，同一局同一条记录的同一检查项只保留一行
+// 复查通过后自动关闭；人工确认无需处理的标记为已忽略，之后不再重新打开
+type ReconcileDiscrepancy struct {
+	gorm.Model
+	GameId     int64  `json:"game_id" gorm:"uniqueIndex:idx_reconcile_item,priority:1"`                // 游戏 ID
+	RecordId   int64  `json:"record_id" gorm:"uniqueIndex:idx_reconcile_item,priority:2"`              // 下注记录 ID，0 表示整局的汇总检查
+	Field      string `json:"field" gorm:"type:varchar(32);uniqueIndex:idx_reconcile_item,priority:3"` // 检查项：bet_total / house_take / ledger_payout 等
+	Expected   string `json:"expected" gorm:"type:varchar(64)"`                                        // 按规则应有的值
+	Actual     string `json:"actual" gorm:"type:varchar(64)"`                                          // 实际的值
+	Status     int8   `json:"status" gorm:"type:tinyint;default:0;index"`                              // 状态：0:待处理 1:已修复（复查通过） 2:已忽略（人工确认）
+	Remark     string `json:"remark" gorm:"type:varchar(255)"`                                         // 处理说明
+	Operator   int64  `json:"operator"`                                                                // 处理人（管理员用户 ID），0 表示系统
+	CheckedAt  int64  `json:"checked_at"`                                                              // 最后一次检查时间
+	ResolvedAt int64  `json:"resolved_at"`                                                             // 关闭时间
+}
+
+// TableName 表名称
+func (*ReconcileDiscrepancy) TableName() string {
+	return "reconcile_discrepancies"
+}
@@ -2,14 +2,15 @@ package model
 
 import (
 	"gorm.io/gorm"
+	"test/pkg/money"
 )
 
 type User struct {
 	gorm.Model
-	Account  string  `gorm:"type:varchar(20);not null"`
-	Password string  `gorm:"type:varchar(255);not null"`
-	Amount   float64 `gorm:"type:decimal(10,2);not null"`
-	Nickname string  `gorm:"type:varchar(20);not null"`
+	Account  string      `gorm:"type:varchar(20);not null"`
+	Password string      `gorm:"type:varchar(255);not null"`
+	Amount   money.Money `gorm:"type:decimal(16,2);not null"`
+	Nickname string      `gorm:"type:varchar(20);not null"`
 }
 
 func (User) TableName() string {
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"gorm.io/gorm"
+	"test/pkg/money"
+)
+
+// UserGamingLimit 玩家自己设置的负责任博彩限制，每个用户一行，金额为 0 表示不限制
+type UserGamingLimit struct {
+	gorm.Model
+	UserId              int64       `json:"user_id" gorm:"uniqueIndex"`
+	DailyWager          money.Money `json:"daily_wager" gorm:"type:decimal(16,2);not null;default:0"`   // 每日下注上限
+	WeeklyWager         money.Money `json:"weekly_wager" gorm:"type:decimal(16,2);not null;default:0"`  // 每周下注上限（周一开始）
+	MonthlyWager        money.Money `json:"monthly_wager" gorm:"type:decimal(16,2);not null;default:0"` // 每月下注上限
+	DailyLoss           money.Money `json:"daily_loss" gorm:"type:decimal(16,2);not null;default:0"`    // 每日净亏损上限
+	WeeklyLoss          money.Money `json:"weekly_loss" gorm:"type:decimal(16,2);not null;default:0"`   // 每周净亏损上限
+	MonthlyLoss         money.Money `json:"monthly_loss" gorm:"type:decimal(16,2);not null;default:0"`  // 每月净亏损上限
+	RealityCheckMinutes int         `json:"reality_check_minutes" gorm:"type:int;not null;default:0"`   // 游戏时长提醒间隔（分钟），0 使用系统默认
+	CoolOffUntil        int64       `json:"cool_off_until"`                                             // 冷静期结束时间，期间不能下注
+	ExcludedUntil       int64       `json:"excluded_until"`                                             // 自我排除结束时间，-1 表示永久
+}
+
+// TableName 表名称
+func (*UserGamingLimit) TableName() string {
+	return "user_gaming_limits"
+}
+
+// UserGamingLimitLog 限制变更的审计记录，每一次修改都写一行
+// 收紧限制立即生效；放宽限制先以待生效状态写入，等待期结束后才应用到 UserGamingLimit
+type UserGamingLimitLog struct {
+	gorm.Model
+	UserId      int64  `json:"user_id" gorm:"index:idx_gaming_log_user,priority:1"`
+	Field       string `json:"field" gorm:"type:varchar(32)"`                                   // 修改项：daily_wager / cool_off / self_exclusion 等
+	OldValue    string `json:"old_value" gorm:"type:varchar(32)"`                               // 修改前的值
+	NewValue    string `json:"new_value" gorm:"type:varchar(32)"`                               // 修改后的值
+	Status      int8   `json:"status" gorm:"type:tinyint;index:idx_gaming_log_user,priority:2"` // 状态：0:待生效 1:已生效 2:已取消（被之后的修改取代）
+	EffectiveAt int64  `json:"effective_at"`                                                    // 生效时间
+	Ip          string `json:"ip" gorm:"type:varchar(64)"`                                      // 操作 IP
+}
+
+// TableName 表名称
+func (*UserGamingLimitLog) TableName() string {
+	return "user_gaming_limit_logs"
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"gorm.io/gorm"
+	"test/pkg/money"
+)
+
+// WalletTransaction 钱包流水（复式记账分录）
+// 每一笔余额变动都会写入两条分录：用户账户一条、平台账户（UserId = 0）一条，金额互为相反数
+// 同一笔交易的两条分录共用 TxNo，所以任意 TxNo 下 SUM(amount) 恒为 0
+type WalletTransaction struct {
+	gorm.Model
+	TxNo          string      `json:"tx_no" gorm:"type:varchar(32);index"`                                // 交易号：借贷两条分录共用
+	UserId        int64       `json:"user_id" gorm:"index:idx_wallet_user_type,priority:1"`               // 账户：用户 ID，0 表示平台账户
+	Type          string      `json:"type" gorm:"type:varchar(20);index:idx_wallet_user_type,priority:2"` // 类型：bet 下注 / payout 派奖 / refund 退款 / adjustment 调账 / switch_fee 换房手续费 / reversal 冲正
+	Amount        money.Money `json:"amount" gorm:"type:decimal(16,2);not null"`                          // 变动金额：正数入账，负数出账
+	BalanceBefore money.Money `json:"balance_before" gorm:"type:decimal(16,2);not null"`                  // 变动前余额（平台账户不维护快照，恒为 0）
+	BalanceAfter  money.Money `json:"balance_after" gorm:"type:decimal(16,2);not null"`                   // 变动后余额（平台账户不维护快照，恒为 0）
+	RefType       string      `json:"ref_type" gorm:"type:varchar(20);index:idx_wallet_ref,priority:1"`   // 关联类型：record 下注记录 / game 游戏
+	RefId         int64       `json:"ref_id" gorm:"index:idx_wallet_ref,priority:2"`                      // 关联 ID：LmDtsRecord.ID 或 LmDtsGame.ID
+	Remark        string      `json:"remark" gorm:"type:varchar(255)"`                                    // 备注
+}
+
+// TableName 表名称
+func (*WalletTransaction) TableName() string {
+	return "wallet_transactions"
+}
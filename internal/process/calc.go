@@ -2,157 +2,145 @@ package process
 
 import (
 	"context"
-	"errors"
-	"github.com/shopspring/decimal"
+	"fmt"
 	"gorm.io/gorm"
-	"math/rand"
+	"gorm.io/gorm/clause"
+	"test/internal/event"
+	"test/internal/game"
 	"test/internal/model"
+	"test/internal/queue"
 	"test/internal/service"
 	"test/pkg/database"
-	"test/pkg/redis"
+	"test/pkg/fair"
+	"test/pkg/money"
 	"time"
 )
 
-// 全局随机源，初始化一次
-var r = rand.New(rand.NewSource(time.Now().UnixNano()))
-
-func getLastGame() (*model.LmDtsGame, error) {
-	//var DtsGame model.LmDtsGame
-	//err := database.DB.Preload("Records").Where("state = ?", 2).Where("end_time <= ?", time.Now()).First(&DtsGame).Error
-	//return &DtsGame, err
-
-	var games []model.LmDtsGame // 定义一个切片
-
-	// 使用 Find 代替 First，找不到记录时 err 为 nil，且不会打印日志
-	err := database.DB.Preload("Records").
+// getDueGameIds 倒计时已结束、等待结算的局
+func getDueGameIds() ([]uint, error) {
+	var ids []uint
+	err := database.DB.Model(&model.LmDtsGame{}).
 		Where("state = ?", 2).
 		Where("end_time <= ?", time.Now().Unix()).
-		Limit(1).
-		Find(&games).Error
-
-	if err != nil {
-		return nil, err // 真正的数据库连接错误等
-	}
+		Order("id asc").
+		Pluck("id", &ids).Error
+	return ids, err
+}
 
-	if len(games) == 0 {
-		return nil, errors.New("未找到记录") // 没找到记录，返回空指针且不报错
+// getGameWithRecords 读取一局游戏及其全部下注记录
+func getGameWithRecords(gameID uint) (*model.LmDtsGame, error) {
+	var game model.LmDtsGame
+	if err := database.DB.Preload("Records").First(&game, gameID).Error; err != nil {
+		return nil, err
 	}
-
-	return &games[0], nil
+	return &game, nil
 }
 
-func CalcHandle() {
+// CalcHandle 驱动一种玩法的状态机：结算到期的局，然后开下一局
+func CalcHandle(ctx context.Context, e game.Engine) {
 
-	// 1. 增加分布式锁，防止 Ticker 导致重叠结算
-	lockKey := "game_dts_calc_lock"
-	ok, err := redis.RedisClient.SetNX(context.Background(), lockKey, "1", 10*time.Second).Result()
-	if err != nil || !ok {
+	// 1. 增加分布式锁，防止 Ticker 导致重叠结算（每种玩法一把锁）
+	lockKey := service.CalcLockKey(e.Name())
+	token, err := service.TryLock(ctx, lockKey, 10*time.Second)
+	if err != nil || token == "" {
 		return
 	}
-	defer redis.RedisClient.Del(context.Background(), lockKey)
-
-	game, err := getLastGame()
+	defer service.Unlock(lockKey, token)
 
-	if err != nil || errors.Is(err, gorm.ErrRecordNotFound) {
-		return
+	// 等待超时的局先处理：强制开始的进入倒计时，退款的作废并开下一局
+	if err := e.ExpireRounds(ctx); err != nil {
+		fmt.Printf("[%s] 等待超时处理失败: %v\n", e.Name(), err)
 	}
 
-	killerRoom := calc(game)
-	//等待前端的动画
-	time.Sleep(time.Second)
-	//添加新的一期
-	addGame(killerRoom)
-	// 删除上期缓存数据
-	err = service.DeleteUserList(context.Background(), int64(game.ID))
+	roundIDs, err := e.DueRounds(ctx)
 	if err != nil {
 		return
 	}
-}
 
-func calc(game *model.LmDtsGame) int64 {
-
-	killRoom := getKillerRoom(game)
-
-	//被刀房间的所有投注
-	//所有房间的投注
-	var totalKillerAmount, totalAmount float64
+	for _, roundID := range roundIDs {
+		outcome, err := e.Settle(ctx, roundID)
+		if err != nil {
+			fmt.Printf("[%s] 结算失败 round=%d: %v\n", e.Name(), roundID, err)
+			continue
+		}
+		//等待前端的动画
+		time.Sleep(time.Second)
+		//添加新的一期
+		if err := e.CreateRound(ctx, outcome); err != nil {
+			fmt.Printf("[%s] 开局失败: %v\n", e.Name(), err)
+		}
+	}
+}
 
-	//query := database.DB.Model(model.LmDtsRecord{}).Where("game_id = ?", game.ID)
-	//query.Session(&gorm.Session{}).Where("room_id IN ?", killRoom).Pluck("SUM(amount)", &totalKillerAmount)
-	//query.Where("room_id IN ?", killRoom).Pluck("SUM(amount)", &totalKillerAmount)
-	//query.Pluck("SUM(amount)", &totalAmount)
+func calc(game *model.LmDtsGame) (int64, error) {
 
-	// 性能优化：用一条查询获取两个统计值
-	err := database.DB.Model(&model.LmDtsRecord{}).Where("game_id = ?", game.ID).
-		Select("SUM(CASE WHEN room_id = ? THEN amount ELSE 0 END) as killer_amount, SUM(amount) as total_amount", killRoom).
-		Row().Scan(&totalKillerAmount, &totalAmount)
+	// 派奖比例以开局时的配置版本为准，改配置不影响已开局的游戏
+	cfg, err := service.GetDtsGameConfig(context.Background(), game)
 	if err != nil {
-		return 0
+		return 0, err
 	}
 
-	// 转换为 Decimal 进行后续运算
-	dTotalAmount := decimal.NewFromFloat(totalAmount)
-	dKillerAmount := decimal.NewFromFloat(totalKillerAmount)
-	dDivisor := dTotalAmount.Sub(dKillerAmount) // 胜出者总投注额
+	var (
+		killRoom   int64
+		clientSeed string
+		settlement *service.DtsSettlement
+	)
+	// 发奖任务必须等结算事务提交后再入队，否则 Worker 可能读不到 state=1 的记录
+	var jobs []queue.BonusJob
+	// 每个玩家本局的净盈亏，提交后写入排行榜
+	profits := make(map[int64]money.Money)
+	// 每个玩家本局的结果，提交后随结算事件推送给本人
+	results := make(map[int64]map[string]interface{})
+	settledAt := time.Now()
 
 	err = database.DB.Transaction(func(tx *gorm.DB) error {
-		var totalPeople int64
-		var totalBonus decimal.Decimal = decimal.NewFromInt(0)
 
-		//// 这里的 divisor 要防止为 0
-		//divisor := totalAmount - totalKillerAmount
+		// 先锁住游戏行再确认状态：期间这一局可能已被后台作废
+		var current model.LmDtsGame
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "state").First(&current, game.ID).Error; err != nil {
+			return err
+		}
+		if current.State != 2 {
+			return fmt.Errorf("game state changed to %d", current.State)
+		}
+
+		// 下注、换房都要先锁游戏行，拿到行锁之后重新读取的记录就是最终的下注，不会漏掉或覆盖
+		if err := tx.Where("game_id = ?", game.ID).Order("id asc").Find(&game.Records).Error; err != nil {
+			return err
+		}
+		killRoom, clientSeed = getKillerRoom(game)
 
-		for _, record := range game.Records {
+		// 派奖公式与离线复算工具共用，见 service.SettleDts
+		settlement = service.SettleDts(game.Records, killRoom, cfg.PayoutRate)
 
-			totalPeople++
+		for i, record := range game.Records {
+			item := settlement.Records[i]
 			record.KillerRoom = killRoom
+			record.State = item.State
 
-			if record.RoomId == killRoom {
+			if item.State == 2 {
 				// 判定为失败（被杀）
-				record.State = 2
-				//database.DB.Save(&record)
-				// ✅ 必须使用 tx!
-				if err := tx.Save(&record).Error; err != nil {
-					return err
-				}
-				continue
+				profits[record.UserId] = item.Amount.Neg()
+			} else {
+				// 2. 将发奖任务推入 Redis 队列 (Job)
+				// 传递 RecordID 即可，后续由 Job 处理器处理
+				jobs = append(jobs, queue.BonusJob{
+					RecordID: record.ID,
+					UserID:   record.UserId,
+					Amount:   item.Payout,
+				})
+				record.Bonus = item.Bonus //获得奖金
+				profits[record.UserId] = item.Bonus
 			}
-
-			// 计算奖金 (防止除以0)
-			bonus := decimal.Zero
-			if dDivisor.GreaterThan(decimal.Zero) {
-				personalAmt := decimal.NewFromFloat(record.Amount)
-				// bonus = killerAmount * 0.9 * (personalAmt / divisor)
-				bonus = dKillerAmount.Mul(decimal.NewFromFloat(0.9)).Mul(personalAmt.Div(dDivisor))
-			}
-			//累计共产生多少奖金
-			totalBonus = totalBonus.Add(bonus)
-
-			//var user model.User
-			//if err := tx.First(&user, record.UserId).Error; err != nil {
-			//	return err
-			//}
-			//user.Amount = bonus + record.Amount
-			//database.DB.Save(&user)
-
-			// ✅ 原子操作：增加余额（不要先 First 再 Save，高并发下会覆盖）
-			// ✅ 原子操作：退回本金 + 增加奖金，防止并发覆盖
-			// 改为异步job执行
-			//totalReturn := bonus.Add(decimal.NewFromFloat(record.Amount))
-			//if err = tx.Model(&model.User{}).Where("id = ?", record.UserId).
-			//	UpdateColumn("amount", gorm.Expr("amount + ?", totalReturn.InexactFloat64())).Error; err != nil {
-			//	return err
-			//}
-
-			// 2. 将发奖任务推入 Redis 队列 (Job)
-			// 传递 RecordID 即可，后续由 Job 处理器处理
-			PushBonusJob(record.ID, record.UserId, bonus.Add(decimal.NewFromFloat(record.Amount)))
-
-			record.Bonus = bonus.InexactFloat64() //获得奖金
-			record.State = 1
-			//database.DB.Save(&record)
-
-			if err = tx.Save(&record).Error; err != nil {
+			results[record.UserId] = recordResult(record)
+
+			// 只写结算产生的字段，不用 Save 把读到的整行写回去
+			// ✅ 必须使用 tx!
+			if err := tx.Model(&record).Updates(map[string]interface{}{
+				"state":       record.State,
+				"bonus":       record.Bonus,
+				"killer_room": record.KillerRoom,
+			}).Error; err != nil {
 				return err
 			}
 		}
@@ -167,65 +155,134 @@ func calc(game *model.LmDtsGame) int64 {
 		// 将年龄设为 0
 		//db.Model(&user).Updates(map[string]interface{}{"age": 0})
 		return tx.Model(game).Updates(map[string]interface{}{
-			"state":               3,                           // 3:已结束（结算完成）
-			"killer_room":         killRoom,                    // 本局杀手房间
-			"total_amount":        totalAmount,                 // 总下注额
-			"total_people":        totalPeople,                 // 总参与人数
-			"total_bonus":         totalBonus.InexactFloat64(), // 本局总派发奖金
-			"total_killer_amount": totalKillerAmount,           // 杀手位总额
-			"end_time":            time.Now().Unix(),           // 记录实际结束时间
+			"state":               3,                            // 3:已结束（结算完成）
+			"killer_room":         killRoom,                     // 本局杀手房间
+			"total_amount":        settlement.TotalAmount,       // 总下注额
+			"total_people":        settlement.TotalPeople,       // 总参与人数
+			"total_bonus":         settlement.TotalBonus,        // 本局总派发奖金
+			"total_killer_amount": settlement.TotalKillerAmount, // 杀手位总额
+			"pool_dust":           settlement.PoolDust,          // 奖池拆分后的零头，归平台
+			"rake_rate":           cfg.RakeRate,                 // 本局使用的抽水比例
+			"rake_amount":         settlement.Rake,              // 抽水金额，没有胜者时为杀手位总额
+			"house_revenue":       settlement.HouseRevenue,      // 平台收入 = 抽水 + 零头
+			"end_time":            settledAt.Unix(),             // 记录实际结束时间
+			"server_seed":         game.ServerSeed,              // 老数据开局时没有种子，这里补写
+			"server_seed_hash":    game.ServerSeedHash,          // 开局承诺的种子哈希
+			"client_seed":         clientSeed,                   // 本局公共种子
 		}).Error
 	})
 
 	if err != nil {
-		return 0
+		return 0, err
+	}
+
+	for _, job := range jobs {
+		PushBonusJob(job)
 	}
 
-	return killRoom
+	// 排行榜：Redis 写失败不影响结算，可以通过重建接口从 lm_dts_record 恢复
+	if err := service.RecordRoundProfits(context.Background(), settledAt, profits); err != nil {
+		fmt.Printf("排行榜更新失败 game_id=%d: %v\n", game.ID, err)
+	}
+	// 推送排行榜要按在线人数查询名次，不让结算等它
+	go PushLeaderboards()
+
+	// 当天的营收汇总：写失败不影响结算，可以通过后台重算接口补上
+	if _, err := service.RefreshDtsDailyRevenue(context.Background(), settledAt); err != nil {
+		fmt.Printf("营收汇总更新失败 game_id=%d: %v\n", game.ID, err)
+	}
+
+	publishDtsEvent(event.GameSettled, game.ID, map[string]interface{}{
+		"killer_room":         killRoom,
+		"total_people":        settlement.TotalPeople,
+		"total_amount":        settlement.TotalAmount,
+		"total_bonus":         settlement.TotalBonus,
+		"total_killer_amount": settlement.TotalKillerAmount,
+		"results":             results,
+	})
+
+	return killRoom, nil
 
 }
 
-func InitGame() {
+func InitGame() error {
 	var count int64
 	if err := database.DB.Model(model.LmDtsGame{}).Count(&count).Error; err != nil {
-		panic(err)
+		return err
 	}
 
 	if count == 0 {
-		addGame(0)
+		return addGame(0)
 	}
+	return nil
 }
 
-func addGame(preKillerRoom int64) {
+func addGame(preKillerRoom int64) error {
+
+	// 开局即生成服务端种子，只公开哈希（承诺），结算后再公开种子本身
+	serverSeed, serverSeedHash, err := fair.NewServerSeed()
+	if err != nil {
+		return err
+	}
+
+	// 记录开局时的配置版本，之后修改配置不会影响这一局
+	cfg, err := service.GetActiveGameConfig(context.Background(), game.TypeDts)
+	if err != nil {
+		return err
+	}
 
 	dtsGame := model.LmDtsGame{
-		State:         1,
-		KillerRoom:    0,
-		PreKillerRoom: preKillerRoom, //上局杀手房间
-		TotalPeople:   0,
-		TotalAmount:   0,
-		TotalBonus:    0,
+		State:          1,
+		KillerRoom:     0,
+		PreKillerRoom:  preKillerRoom, //上局杀手房间
+		TotalPeople:    0,
+		TotalAmount:    0,
+		TotalBonus:     0,
+		ServerSeed:     serverSeed,
+		ServerSeedHash: serverSeedHash,
+		ConfigVersion:  cfg.Version,
 	}
 
 	if err := database.DB.Create(&dtsGame).Error; err != nil {
-		panic(err)
+		return err
 	}
 
 	service.SetLastGameId(context.Background(), dtsGame.ID)
+	publishDtsEvent(event.GameCreated, dtsGame.ID, nil)
+	return nil
 }
 
-func getKillerRoom(game *model.LmDtsGame) int64 {
+// publishDtsEvent 发布大逃杀的领域事件（calc 的参数名 game 遮住了 game 包，统一从这里发）
+func publishDtsEvent(eventType string, gameID uint, data map[string]interface{}) {
+	event.Publish(event.Event{Type: eventType, GameType: game.TypeDts, GameID: gameID, Data: data})
+}
 
-	var roomIds []int64
+// recordResult 结算后推送给玩家本人的结果
+func recordResult(record model.LmDtsRecord) map[string]interface{} {
+	return map[string]interface{}{
+		"record_id":   record.ID,
+		"room_id":     record.RoomId,
+		"killer_room": record.KillerRoom,
+		"state":       record.State, // 1:胜 2:被杀
+		"amount":      record.Amount,
+		"bonus":       record.Bonus,
+	}
+}
 
-	database.DB.Model(&model.LmDtsRecord{}).
-		Where("game_id = ?", game.ID).
-		Distinct().
-		Pluck("room_id", &roomIds)
-	if len(roomIds) == 0 {
-		return 0
+// getKillerRoom 用 服务端种子 + 公共种子 + 局号 从有下注的房间中选出杀手房间
+// 返回杀手房间和本局公共种子，两者都会写回游戏表，供 /api/dts/verify 校验
+func getKillerRoom(game *model.LmDtsGame) (int64, string) {
+
+	// 升级前创建的局没有种子，补一个，至少保证之后可以复算
+	if game.ServerSeed == "" {
+		serverSeed, serverSeedHash, err := fair.NewServerSeed()
+		if err != nil {
+			return 0, ""
+		}
+		game.ServerSeed, game.ServerSeedHash = serverSeed, serverSeedHash
 	}
 
-	index := r.Intn(len(roomIds))
-	return roomIds[index]
+	clientSeed := service.CombineClientSeed(game.Records)
+	rooms := service.CandidateRooms(game.Records)
+	return service.PickKillerRoom(game.ServerSeed, clientSeed, game.ID, rooms), clientSeed
 }
@@ -2,13 +2,32 @@ package process
 
 import (
 	"context"
+	"fmt"
+	"test/internal/event"
+	"test/internal/game"
+	"test/internal/service"
 	"test/pkg/util"
 	"time"
 )
 
 func Handle(ctx context.Context) {
 
-	InitGame()
+	// 1. 每种玩法都保证有一局可以下注
+	for _, e := range game.All() {
+		if err := e.Init(ctx); err != nil {
+			panic(err)
+		}
+	}
+
+	// 上次进程退出时可能留下没结算的局、没有下一局、没派出去的奖金，先修复再开始 tick
+	if _, err := service.RecoverDts(ctx); err != nil {
+		fmt.Printf("崩溃恢复失败: %v\n", err)
+	}
+
+	// 排行榜在 Redis 里，Redis 被清空后从下注记录重建
+	if err := service.EnsureLeaderboards(ctx); err != nil {
+		fmt.Printf("排行榜重建失败: %v\n", err)
+	}
 
 	// 2. 启动结算/状态机协程 (独立运行)
 	util.GoSafe(func() {
@@ -17,16 +36,24 @@ func Handle(ctx context.Context) {
 		for {
 			select {
 			case <-ticker.C:
-				CalcHandle()
+				for _, e := range game.All() {
+					CalcHandle(ctx, e)
+				}
 			case <-ctx.Done():
 				return
 			}
 		}
 	})
 
-	// 2. 启动推送任务 (使用 GoSafe)
+	// 3. 事件驱动的增量推送
+	event.Subscribe(HandleEvent)
 	util.GoSafe(func() {
-		ticker := time.NewTicker(1 * time.Second)
+		event.Run(ctx)
+	})
+
+	// 4. 定时全量快照兜底
+	util.GoSafe(func() {
+		ticker := time.NewTicker(SnapshotInterval)
 		defer ticker.Stop()
 		for {
 			select {
@@ -38,4 +65,34 @@ func Handle(ctx context.Context) {
 		}
 	})
 
+	// 5. 观众的公开数据，数据有变化才推
+	util.GoSafe(func() {
+		ticker := time.NewTicker(SpectatorInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				StartSpectatorPush()
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+
+	// 6. 定时对账：下注、奖金和钱包流水逐局核对，差异写入 reconcile_discrepancies
+	util.GoSafe(func() {
+		ticker := time.NewTicker(service.ReconcileInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := service.RunReconcile(ctx); err != nil {
+					fmt.Printf("定时对账失败: %v\n", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+
 }
@@ -0,0 +1,292 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"test/internal/event"
+	"test/internal/game"
+	"test/internal/model"
+	"test/internal/service"
+	"test/pkg/util"
+)
+
+func init() {
+	game.Register(&DtsEngine{})
+}
+
+// DtsEngine 大逃杀：玩家选房间下注，倒计时结束后随机一个杀手房间，
+// 杀手房间的下注按比例分给其他房间的玩家
+type DtsEngine struct{}
+
+func (e *DtsEngine) Type() int {
+	return game.TypeDts
+}
+
+func (e *DtsEngine) Name() string {
+	return "dts"
+}
+
+func (e *DtsEngine) Init(ctx context.Context) error {
+	return InitGame()
+}
+
+func (e *DtsEngine) CreateRound(ctx context.Context, prevOutcome int64) error {
+	return addGame(prevOutcome)
+}
+
+func (e *DtsEngine) DueRounds(ctx context.Context) ([]uint, error) {
+	return getDueGameIds()
+}
+
+func (e *DtsEngine) ExpireRounds(ctx context.Context) error {
+	return service.ExpireDtsRounds(ctx)
+}
+
+func (e *DtsEngine) ValidateBet(ctx context.Context, tx *gorm.DB, bet game.Bet) error {
+	// 锁住游戏行再检查状态：结算、作废也先锁这一行，拿到锁时这一局要么还能下注，要么已经结束
+	dtsGame, err := service.LockDtsGame(tx, bet.GameID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// 金额校验：追加下注同样不允许 0 或负数，否则扣款会变成加款
+	if bet.Amount <= 0 {
+		return util.NewBizErr("InvalidAmount", nil)
+	}
+
+	// 房间范围、单注金额、每局累计、房间总额、下注次数，按本局的配置版本校验
+	return service.CheckDtsBetLimits(tx, dtsGame, bet.UserID, bet.RoomID, bet.Amount)
+}
+
+func (e *DtsEngine) SelectOutcome(ctx context.Context, roundID uint) (int64, error) {
+	dtsGame, err := getGameWithRecords(roundID)
+	if err != nil {
+		return 0, err
+	}
+	killerRoom, _ := getKillerRoom(dtsGame)
+	return killerRoom, nil
+}
+
+func (e *DtsEngine) Settle(ctx context.Context, roundID uint) (int64, error) {
+	dtsGame, err := getGameWithRecords(roundID)
+	if err != nil {
+		return 0, err
+	}
+	// 只结算倒计时已结束的局，防止重复结算
+	if dtsGame.State != 2 || dtsGame.EndTime > time.Now().Unix() {
+		return 0, errors.New("game is not due")
+	}
+
+	killerRoom, err := calc(dtsGame)
+	if err != nil {
+		return 0, err
+	}
+
+	// 删除上期缓存数据
+	_ = service.DeleteUserList(ctx, int64(dtsGame.ID))
+	return killerRoom, nil
+}
+
+func (e *DtsEngine) StatePayload(ctx context.Context, userIDs []int64) (*game.Payload, error) {
+
+	// 1. 获取最新游戏 ID
+	gameID, _ := service.GetLastGameId(ctx)
+	if gameID == 0 {
+		return nil, nil
+	}
+	// 2. 获取游戏基础数据（所有用户共享，只查一次）
+	dtsGame, err := service.GetGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := service.GetDtsGameConfig(ctx, dtsGame)
+	if err != nil {
+		return nil, err
+	}
+	userList, _ := service.GetUserList(ctx, int64(dtsGame.ID))
+
+	payload := &game.Payload{
+		Broadcast: map[string]interface{}{
+			"game_type":           e.Type(),
+			"game_id":             dtsGame.ID,
+			"start_time":          dtsGame.StartTime, //开始时间
+			"end_time":            dtsGame.EndTime,   //结束时间
+			"state":               dtsGame.State,     // 状态：1:进行中 2:开始倒计时 3:结束 4:作废
+			"timer":               math.Max(0, float64(dtsGame.EndTime-time.Now().Unix())),
+			"killer_room":         dtsGame.KillerRoom,
+			"pre_killer_room":     dtsGame.PreKillerRoom,
+			"server_seed_hash":    dtsGame.ServerSeedHash, // 本局种子承诺，结算后可校验
+			"join_people":         len(userList),          // 加入的人
+			"max_people":          cfg.MaxPeople,          // 开始倒计时所需人数（本局配置）
+			"bet_limits":          betLimits(cfg),         // 本局的下注限制，0 表示不限制
+			"config_version":      dtsGame.ConfigVersion,  // 本局使用的配置版本
+			"total_killer_amount": dtsGame.TotalKillerAmount,
+			"user_list":           userList,
+			"room_list":           service.CalcRoomAmount(userList, cfg.RoomCount),
+			"timestamp":           time.Now().Unix(),
+		},
+		Users: make(map[int64]interface{}, len(userIDs)),
+	}
+
+	// 3. 个人数据直接从同一份用户列表里取，不再逐个用户查 Redis
+	wanted := make(map[int64]bool, len(userIDs))
+	for _, userID := range userIDs {
+		wanted[userID] = true
+	}
+	for _, item := range userList {
+		if wanted[item.UserID] {
+			payload.Users[item.UserID] = userPayload(item)
+		}
+	}
+	return payload, nil
+}
+
+func (e *DtsEngine) EventPayload(ctx context.Context, ev event.Event) (*game.Payload, error) {
+	if ev.GameType != e.Type() {
+		return nil, nil
+	}
+
+	delta := map[string]interface{}{
+		"event":     ev.Type,
+		"game_type": e.Type(),
+		"game_id":   ev.GameID,
+		"timestamp": ev.At,
+	}
+	payload := &game.Payload{Broadcast: delta}
+
+	switch ev.Type {
+	case event.BetPlaced, event.RoomSwitched:
+		// 只推送变化的那个玩家和他所在房间的新合计，换房时同时推送原房间的新合计
+		dtsGame, err := service.GetGame(ev.GameID)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := service.GetDtsGameConfig(ctx, dtsGame)
+		if err != nil {
+			return nil, err
+		}
+		userList, err := service.GetUserList(ctx, int64(ev.GameID))
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range userList {
+			if item.UserID == ev.UserID {
+				delta["user"] = item
+				payload.Users = map[int64]interface{}{ev.UserID: userPayload(item)}
+			}
+		}
+		roomID, _ := ev.Data["room_id"].(int)
+		fromRoom, _ := ev.Data["from_room"].(int)
+		for _, room := range service.CalcRoomAmount(userList, cfg.RoomCount) {
+			switch room.RoomID {
+			case roomID:
+				delta["room"] = room
+			case fromRoom:
+				delta["from_room"] = room
+			}
+		}
+		delta["join_people"] = len(userList)
+
+	case event.CountdownStarted:
+		delta["state"] = 2
+		delta["start_time"] = ev.Data["start_time"]
+		delta["end_time"] = ev.Data["end_time"]
+		if forced, ok := ev.Data["forced"]; ok {
+			// 等待超时、人数不够也强制开始
+			delta["forced"] = forced
+		}
+
+	case event.GameSettled:
+		delta["state"] = 3
+		for _, key := range []string{"killer_room", "total_people", "total_amount", "total_bonus", "total_killer_amount"} {
+			delta[key] = ev.Data[key]
+		}
+		// 每个参与者单独收到自己的输赢
+		if results, ok := ev.Data["results"].(map[int64]map[string]interface{}); ok {
+			payload.Users = make(map[int64]interface{}, len(results))
+			for userID, result := range results {
+				payload.Users[userID] = result
+			}
+		}
+
+	case event.GameVoided:
+		delta["state"] = 4
+		delta["reason"] = ev.Data["reason"]
+		// 每个参与者单独收到自己的退款明细
+		if refunds, ok := ev.Data["refunds"].(map[int64]map[string]interface{}); ok {
+			payload.Users = make(map[int64]interface{}, len(refunds))
+			for userID, refund := range refunds {
+				payload.Users[userID] = refund
+			}
+		}
+
+	case event.GameCreated:
+		// 新的一局，直接推全量（此时用户列表为空，数据量很小）
+		return e.StatePayload(ctx, nil)
+
+	default:
+		return nil, nil
+	}
+	return payload, nil
+}
+
+func (e *DtsEngine) SpectatorPayload(ctx context.Context) (interface{}, error) {
+	gameID, _ := service.GetLastGameId(ctx)
+	if gameID == 0 {
+		return nil, nil
+	}
+	dtsGame, err := service.GetGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := service.GetDtsGameConfig(ctx, dtsGame)
+	if err != nil {
+		return nil, err
+	}
+	userList, _ := service.GetUserList(ctx, int64(dtsGame.ID))
+
+	// 只有汇总数据：不带 user_list，也没有任何人的下注金额和奖金
+	return map[string]interface{}{
+		"game_type":        e.Type(),
+		"game_id":          dtsGame.ID,
+		"start_time":       dtsGame.StartTime,
+		"end_time":         dtsGame.EndTime,
+		"state":            dtsGame.State,
+		"timer":            math.Max(0, float64(dtsGame.EndTime-time.Now().Unix())),
+		"killer_room":      dtsGame.KillerRoom,
+		"pre_killer_room":  dtsGame.PreKillerRoom,
+		"server_seed_hash": dtsGame.ServerSeedHash,
+		"join_people":      len(userList),
+		"max_people":       cfg.MaxPeople,
+		"room_list":        service.CalcRoomAmount(userList, cfg.RoomCount),
+	}, nil
+}
+
+// userPayload 用户自己的数据
+func userPayload(item service.DtsUserCache) map[string]interface{} {
+	return map[string]interface{}{
+		"user_id":     item.UserID,
+		"user_bonus":  item.Bonus,
+		"user_amount": item.Amount,
+		"room_id":     item.RoomID,
+	}
+}
+
+// betLimits 推给客户端的下注限制，前端据此提前拦截
+func betLimits(cfg *model.GameConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"room_count":          cfg.RoomCount,
+		"min_bet":             cfg.MinBet,
+		"max_bet":             cfg.MaxBet,
+		"max_user_total":      cfg.MaxUserTotal,
+		"max_room_total":      cfg.MaxRoomTotal,
+		"max_bets_per_round":  cfg.MaxBetsPerRound,
+		"max_switches":        cfg.MaxSwitches, // -1 表示不允许换房
+		"switch_lock_seconds": cfg.SwitchLockSeconds,
+		"switch_fee":          cfg.SwitchFee,
+	}
+}
@@ -2,19 +2,15 @@ package process
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 
-	"github.com/shopspring/decimal"
-	"test/pkg/redis"
+	"test/internal/queue"
 )
 
-func PushBonusJob(recordID uint, userID int64, totalAmount decimal.Decimal) {
-	jobData := map[string]interface{}{
-		"record_id": recordID,
-		"user_id":   userID,
-		"amount":    totalAmount.InexactFloat64(),
-	}
-	payload, _ := json.Marshal(jobData)
+func PushBonusJob(job queue.BonusJob) {
 	// 推送到 Redis 队列
-	redis.RedisClient.LPush(context.Background(), "game_bonus_queue", payload)
+	if err := queue.Push(context.Background(), job); err != nil {
+		// 入队失败不影响已提交的结算，Paid=0 的胜利记录可以重新入队补发
+		fmt.Printf("发奖任务入队失败 record_id=%d: %v\n", job.RecordID, err)
+	}
 }
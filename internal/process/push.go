@@ -1,65 +1,286 @@
 package process
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"math"
+	"fmt"
+	"sync"
+	"time"
+
+	"test/internal/event"
+	"test/internal/game"
 	"test/internal/service"
 	"test/internal/websocket"
-	"time"
 )
 
+// SnapshotInterval 全量快照的推送间隔
+// 日常变化由事件增量推送，全量快照只用来兜底（丢事件、前端漏处理）和刷新倒计时
+const SnapshotInterval = 10 * time.Second
+
 func StartPushTask() {
 
-	// 1. 获取最新游戏 ID
-	gameID, _ := service.GetLastGameId(context.Background())
-	if gameID == 0 {
+	clients := websocket.GlobalHub.GetAllClients()
+	if len(clients) == 0 {
 		return
 	}
-	// 2. 获取游戏基础数据
-	game, _ := service.GetGame(gameID)
-	userList, _ := service.GetUserList(context.Background(), int64(game.ID))
-	// 对应 refreshData，准备公共部分
-
-	// 3. 广播给所有在线用户
-	clients := websocket.GlobalHub.GetAllClients()
+	// 同一用户可能有多个会话，个人数据只需要组装一次
+	seen := make(map[int64]bool, len(clients))
+	userIDs := make([]int64, 0, len(clients))
 	for _, client := range clients {
-		// 获取该用户的个性化数据
-		userData, err := service.GetUserGameData(context.Background(), int64(game.ID), client.ID)
+		if !seen[client.ID] {
+			seen[client.ID] = true
+			userIDs = append(userIDs, client.ID)
+		}
+	}
+
+	// 每种玩法各自组装全量数据，字段名为 <玩法>_data，例如 dts_data
+	ctx := context.Background()
+	for _, e := range game.All() {
+		// 先取序号再取数据：期间产生的增量序号更大，客户端不会把它当成已包含在快照里
+		seq := service.CurrentStreamSeq(ctx, e.Name())
+		payload, err := e.StatePayload(ctx, userIDs)
+		if err != nil || payload == nil {
+			continue
+		}
+		// 每个节点只给自己本地的连接推快照
+		sendPayloadLocal(e.Name(), seq, payload)
+	}
+}
+
+// HandleEvent 领域事件 -> 各玩法的增量推送，字段名为 <玩法>_delta，个人部分为 <玩法>_user
+func HandleEvent(ctx context.Context, ev event.Event) {
+	if ev.Type == event.SpectatorJoined {
+		sendSpectatorSnapshot(ev.SessionID)
+		return
+	}
+
+	// 新连接：补发断线期间的消息或者一次全量（事件和连接在同一个节点上）
+	if ev.Type == event.UserConnected {
+		for _, e := range game.All() {
+			resumeSession(ctx, e, ev)
+		}
+		return
+	}
+
+	e, ok := game.Get(ev.GameType)
+	if !ok {
+		return
+	}
+	payload, err := e.EventPayload(ctx, ev)
+	if err != nil || payload == nil {
+		return
+	}
+	// 新开一局推全量，其余推增量
+	if ev.Type == event.GameCreated {
+		sendPayload(ctx, e.Name(), e.Name()+"_data", payload)
+		return
+	}
+	sendPayload(ctx, e.Name(), e.Name()+"_delta", payload)
+}
+
+// resumeSession 连接带了 last_seq 且缺口还在缓冲区内时只补发缺失的广播，否则发全量
+// 补发期间新的广播也会实时到达，客户端按 seq 去重
+// 要补发的消息超过了连接发送缓冲的空位时也改发全量（带 resync 标记），不能让补发的消息被静默丢掉
+func resumeSession(ctx context.Context, e game.Engine, ev event.Event) {
+	seq := service.CurrentStreamSeq(ctx, e.Name())
+	payload, err := e.StatePayload(ctx, []int64{ev.UserID})
+	if err != nil || payload == nil {
+		return
+	}
+
+	lastSeq, ok := ev.Data["last_seq"].(int64)
+	if !ok {
+		sendSessionLocal(ev.UserID, ev.SessionID, e.Name(), seq, payload, false)
+		return
+	}
+
+	// 断线期间发给自己的结算结果、退款明细不在广播流里，单独补发
+	userMessages, err := service.ReplayUserStream(ctx, e.Name(), ev.UserID, lastSeq)
+	if err != nil {
+		userMessages = nil
+	}
+	resync := true
+	messages, ok, err := service.ReplayStream(ctx, e.Name(), lastSeq)
+	if err == nil && ok {
+		free, online := websocket.GlobalHub.SessionFreeSlots(ev.UserID, ev.SessionID)
+		if !online {
+			return
+		}
+		// 补发的广播 + 个人消息 + 一条个人全量
+		if len(messages)+len(userMessages)+1 <= free {
+			for _, msg := range messages {
+				websocket.GlobalHub.SendToSessionLocal(ev.UserID, ev.SessionID, msg)
+			}
+			// 个人数据不在广播流里，单独补一次
+			payload = &game.Payload{Users: payload.Users}
+			resync = false
+		}
+	}
+	sendSessionLocal(ev.UserID, ev.SessionID, e.Name(), seq, payload, resync)
+	for _, msg := range userMessages {
+		websocket.GlobalHub.SendToSessionLocal(ev.UserID, ev.SessionID, msg)
+	}
+}
+
+// sendPayload 公共部分编号后广播，个人部分单独发送，集群模式下经 Redis 转发到所有节点
+func sendPayload(ctx context.Context, channel, field string, payload *game.Payload) {
+	var seq int64
+	if payload.Broadcast != nil {
+		data, s, err := service.AppendStream(ctx, channel, map[string]interface{}{field: payload.Broadcast})
 		if err != nil {
+			// Redis 写缓冲失败也要推送，只是这条消息没有序号、不能补发
+			data, _ = json.Marshal(map[string]interface{}{field: payload.Broadcast})
+		}
+		seq = s
+		websocket.GlobalHub.Broadcast(data)
+	}
+	for userID, data := range payload.Users {
+		msg, _ := json.Marshal(map[string]interface{}{channel + "_user": data})
+		// 个人消息按同时发出的广播序号保存，断线重连时补发
+		if seq > 0 {
+			if err := service.AppendUserStream(ctx, channel, userID, seq, msg); err != nil {
+				fmt.Printf("[%s] 保存个人消息失败 user_id=%d: %v\n", channel, userID, err)
+			}
+		}
+		websocket.GlobalHub.SendToUser(userID, msg)
+	}
+}
+
+// sendPayloadLocal 全量快照只推给本节点的连接
+func sendPayloadLocal(channel string, seq int64, payload *game.Payload) {
+	if payload.Broadcast != nil {
+		websocket.GlobalHub.BroadcastLocal(snapshotMessage(channel, seq, payload.Broadcast))
+	}
+	for userID, data := range payload.Users {
+		msg, _ := json.Marshal(map[string]interface{}{channel + "_user": data})
+		websocket.GlobalHub.SendToUserLocal(userID, msg)
+	}
+}
+
+// sendSessionLocal 只推给用户的某一个会话（新连接补发全量，不打扰用户的其他会话）
+// resync 为 true 表示断线太久、没有逐条补发，客户端应丢弃本地的增量状态，以这份全量为准
+func sendSessionLocal(userID int64, sessionID, channel string, seq int64, payload *game.Payload, resync bool) {
+	if payload.Broadcast != nil {
+		msg := snapshotMessage(channel, seq, payload.Broadcast)
+		if resync {
+			msg = resyncMessage(channel, seq, payload.Broadcast)
+		}
+		websocket.GlobalHub.SendToSessionLocal(userID, sessionID, msg)
+	}
+	if data, ok := payload.Users[userID]; ok {
+		msg, _ := json.Marshal(map[string]interface{}{channel + "_user": data})
+		websocket.GlobalHub.SendToSessionLocal(userID, sessionID, msg)
+	}
+}
+
+// snapshotMessage 全量快照带上频道当前的序号，客户端从这个序号开始接增量
+func snapshotMessage(channel string, seq int64, data interface{}) []byte {
+	msg, _ := json.Marshal(snapshotBody(channel, seq, data))
+	return msg
+}
+
+// resyncMessage 带 resync 标记的全量快照：缺失的增量没有补发
+func resyncMessage(channel string, seq int64, data interface{}) []byte {
+	body := snapshotBody(channel, seq, data)
+	body["resync"] = true
+	msg, _ := json.Marshal(body)
+	return msg
+}
+
+func snapshotBody(channel string, seq int64, data interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		channel + "_data": data,
+		"channel":         channel,
+		"seq":             seq,
+	}
+}
+
+// SpectatorInterval 观众推送的最小间隔
+// 观众只看公开数据，不跟事件走：每个节点每个间隔最多查一次、推一次，数据没变就不推，
+// 开销只跟节点数有关，跟观众人数无关
+const SpectatorInterval = time.Second
+
+var (
+	spectatorMu   sync.Mutex
+	spectatorLast = make(map[string][]byte) // 玩法 -> 上一次推送的内容
+)
+
+// StartSpectatorPush 给本节点的观众推送各玩法的公开数据，字段名为 <玩法>_public
+func StartSpectatorPush() {
+	if len(websocket.SpectatorHub.GetAllClients()) == 0 {
+		return
+	}
+	for _, msg := range buildSpectatorMessages(true) {
+		websocket.SpectatorHub.BroadcastLocal(msg)
+	}
+}
+
+// buildSpectatorMessages 组装公开数据
+// broadcast 为 true 时只返回和上次广播不同的，并记为最新一次广播；单发给新观众时不影响广播的比较
+func buildSpectatorMessages(broadcast bool) map[string][]byte {
+	ctx := context.Background()
+	messages := make(map[string][]byte)
+
+	spectatorMu.Lock()
+	defer spectatorMu.Unlock()
+	for _, e := range game.All() {
+		data, err := e.SpectatorPayload(ctx)
+		if err != nil || data == nil {
 			continue
 		}
-		pushData := map[string]interface{}{
-			"dts_data": map[string]interface{}{
-				"user_id":     client.ID,
-				"user_bonus":  userData.Bonus,
-				"user_amount": userData.Amount,
-
-				"game_type":           1,
-				"game_id":             game.ID,
-				"start_time":          game.StartTime, //开始时间
-				"end_time":            game.EndTime,   //结束时间
-				"state":               game.State,     // 状态：1:进行中 2:开始倒计时 3:结束
-				"timer":               math.Max(0, float64(game.EndTime-time.Now().Unix())),
-				"killer_room":         game.KillerRoom,
-				"pre_killer_room":     game.PreKillerRoom,
-				"join_people":         len(userList), // 加入的人
-				"max_people":          3,             //房间人数限制
-				"total_killer_amount": game.TotalKillerAmount,
-				"user_list":           userList,
-				"room_list":           service.CalcRoomAmount(userList),
-				"timestamp":           time.Now().Unix(),
-			},
+		msg, _ := json.Marshal(map[string]interface{}{e.Name() + "_public": data})
+		if broadcast {
+			if bytes.Equal(msg, spectatorLast[e.Name()]) {
+				continue
+			}
+			spectatorLast[e.Name()] = msg
 		}
+		messages[e.Name()] = msg
+	}
+	return messages
+}
 
-		payload, _ := json.Marshal(pushData)
+// sendSpectatorSnapshot 新观众连上后立即给一份，不用等下一次变化
+func sendSpectatorSnapshot(sessionID string) {
+	for _, msg := range buildSpectatorMessages(false) {
+		websocket.SpectatorHub.SendToSessionLocal(0, sessionID, msg)
+	}
+}
+
+// RankPushLimit 推送的排行榜条数
+const RankPushLimit = 10
+
+// PushLeaderboards 结算后推送各周期的排行榜：前 N 名所有人相同，只查一次，各用户的名次批量查询
+// 结算只在拿到锁的节点上执行，所以要取全集群的在线用户；在线人数多时比较耗时，调用方放到 goroutine 里执行
+func PushLeaderboards() {
+	ctx := context.Background()
+	userIDs, err := websocket.GlobalHub.OnlineUserIDs(ctx)
+	if err != nil || len(userIDs) == 0 {
+		return
+	}
 
-		// 异步发送，不阻塞当前广播循环
-		select {
-		case client.Send <- payload:
-		default:
-			// 通道满说明网络卡，可跳过
+	boards := make(map[string]*service.Leaderboard, len(service.RankPeriods))
+	for _, period := range service.RankPeriods {
+		board, err := service.GetLeaderboardTop(ctx, period, RankPushLimit)
+		if err != nil {
+			return
 		}
+		boards[period] = board
+	}
+	ranks, err := service.GetUsersRanks(ctx, userIDs)
+	if err != nil {
+		return
 	}
 
+	for _, userID := range userIDs {
+		rankData := make(map[string]service.Leaderboard, len(boards))
+		for period, board := range boards {
+			mine := *board
+			mine.Mine = ranks[period][userID]
+			rankData[period] = mine
+		}
+		payload, _ := json.Marshal(map[string]interface{}{"dts_rank": rankData})
+		websocket.GlobalHub.SendToUser(userID, payload)
+	}
 }
@@ -2,39 +2,94 @@ package process
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 	"test/internal/model"
+	"test/internal/queue"
+	"test/internal/service"
 	"test/pkg/database"
-	"test/pkg/redis"
+	"test/pkg/util"
 )
 
 func StartBonusWorker(ctx context.Context) {
+
+	// 回收协程：超时未确认的任务、到期的重试任务放回待处理队列
+	util.GoSafe(func() {
+		ticker := time.NewTicker(1 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := queue.Reap(ctx); err != nil {
+					fmt.Printf("发奖队列回收失败: %v\n", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			// 使用 BRPOP 阻塞式获取任务，减少 CPU 消耗
-			result, err := redis.RedisClient.BRPop(ctx, 0, "game_bonus_queue").Result()
-			if err != nil {
-				continue
-			}
-
-			var job map[string]interface{}
-			json.Unmarshal([]byte(result[1]), &job)
+		}
 
-			// 执行真正的加钱操作
-			err = database.DB.Model(&model.User{}).
-				Where("id = ?", job["user_id"]).
-				UpdateColumn("amount", gorm.Expr("amount + ?", job["amount"])).Error
+		delivery, err := queue.Fetch(ctx)
+		if err != nil {
+			fmt.Printf("获取发奖任务失败: %v\n", err)
+		}
+		if delivery == nil {
+			// 队列为空（或 Redis 出错）时稍等再取，减少空转
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(500 * time.Millisecond):
+			}
+			continue
+		}
 
-			if err != nil {
-				// 失败处理：可以重新入队或记录错误日志记录
-				fmt.Printf("发奖失败: %v", err)
+		// 执行真正的加钱操作
+		if err = applyBonus(delivery.Job); err != nil {
+			fmt.Printf("发奖失败 record_id=%d attempts=%d: %v\n", delivery.Job.RecordID, delivery.Job.Attempts+1, err)
+			if err = queue.Fail(ctx, delivery, err); err != nil {
+				// 标记失败也没成功，任务仍在 processing 里，超时后会被回收重试
+				fmt.Printf("发奖任务标记失败出错: %v\n", err)
 			}
+			continue
+		}
+
+		if err = queue.Ack(ctx, delivery); err != nil {
+			// Ack 失败任务会被再次投递，applyBonus 是幂等的，不会重复加钱
+			fmt.Printf("发奖任务确认失败: %v\n", err)
 		}
 	}
 }
+
+// applyBonus 以 record_id 幂等地派奖：先把记录从 未派 改成 已派，改成功了才加余额
+// 两步在同一个事务里，重复投递的任务会因为 RowsAffected = 0 直接跳过
+func applyBonus(job queue.BonusJob) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.LmDtsRecord{}).
+			Where("id = ? AND user_id = ? AND state = ? AND paid = ?", job.RecordID, job.UserID, 1, 0).
+			UpdateColumn("paid", 1)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		_, err := service.ChangeBalance(tx, service.WalletChange{
+			UserID:  job.UserID,
+			Type:    service.TxTypePayout,
+			Amount:  job.Amount,
+			RefType: service.RefTypeRecord,
+			RefID:   int64(job.RecordID),
+		})
+		return err
+	})
+}
@@ -0,0 +1,282 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"test/pkg/money"
+	myredis "test/pkg/redis"
+)
+
+// 发奖队列（至少一次投递）
+//
+//	game_bonus_queue      待处理 List：LPUSH 入队，RPOP 出队
+//	game_bonus_processing 处理中 ZSet：score = 可见性超时的截止时间(毫秒)，Worker 崩溃后由 Reap 放回待处理
+//	game_bonus_retry      重试 ZSet：score = 下次可执行时间(毫秒)，指数退避
+//	game_bonus_dead       死信 List：超过最大重试次数的任务，等待人工重放
+//
+// 同一个任务可能被投递多次，消费方必须以 record_id 做幂等
+const (
+	ReadyKey      = "game_bonus_queue"
+	ProcessingKey = "game_bonus_processing"
+	RetryKey      = "game_bonus_retry"
+	DeadKey       = "game_bonus_dead"
+)
+
+const (
+	VisibilityTimeout = 30 * time.Second // 取出后多久没有 Ack 视为 Worker 已崩溃
+	MaxAttempts       = 5                // 最多执行次数，超过进入死信
+	BaseBackoff       = 2 * time.Second  // 第一次重试的等待时间，之后每次翻倍
+	MaxBackoff        = 5 * time.Minute  // 退避上限
+)
+
+// BonusJob 发奖任务：本金 + 奖金一起退回给胜利的玩家
+type BonusJob struct {
+	RecordID  uint        `json:"record_id"`
+	UserID    int64       `json:"user_id"`
+	Amount    money.Money `json:"amount"`
+	Attempts  int         `json:"attempts,omitempty"`   // 已失败次数
+	LastError string      `json:"last_error,omitempty"` // 最近一次失败原因
+	FailedAt  int64       `json:"failed_at,omitempty"`  // 进入死信的时间
+}
+
+// UnmarshalJSON 兼容升级前入队的任务：金额是浮点数，可能带很多位小数，按四舍五入到分读取
+func (j *BonusJob) UnmarshalJSON(data []byte) error {
+	type plain BonusJob
+	var raw struct {
+		plain
+		Amount json.Number `json:"amount"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*j = BonusJob(raw.plain)
+	if raw.Amount == "" {
+		return nil
+	}
+	amount, err := decimal.NewFromString(raw.Amount.String())
+	if err != nil {
+		return err
+	}
+	j.Amount = money.FromDecimal(amount)
+	return nil
+}
+
+// Delivery 取出的任务，Ack / Fail 时需要原始报文定位 processing 里的成员
+type Delivery struct {
+	Job     BonusJob
+	Payload string
+}
+
+// fetchScript 原子地从待处理队列取出一个任务并登记到 processing
+// 不用 BRPOP 是因为弹出和登记之间一旦崩溃，任务就丢了
+var fetchScript = redis.NewScript(`
+local payload = redis.call('RPOP', KEYS[1])
+if not payload then
+	return false
+end
+redis.call('ZADD', KEYS[2], ARGV[1], payload)
+return payload
+`)
+
+// reapScript 把到期的成员从 ZSet 挪回待处理队列
+var reapScript = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 100)
+for _, payload in ipairs(items) do
+	redis.call('ZREM', KEYS[1], payload)
+	redis.call('RPUSH', KEYS[2], payload)
+end
+return #items
+`)
+
+// Push 入队
+func Push(ctx context.Context, job BonusJob) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return myredis.RedisClient.LPush(ctx, ReadyKey, payload).Err()
+}
+
+// Fetch 取出一个任务，队列为空时返回 nil
+func Fetch(ctx context.Context) (*Delivery, error) {
+	deadline := time.Now().Add(VisibilityTimeout).UnixMilli()
+	payload, err := fetchScript.Run(ctx, myredis.RedisClient, []string{ReadyKey, ProcessingKey}, deadline).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var job BonusJob
+	if err := json.Unmarshal([]byte(payload), &job); err != nil {
+		// 报文损坏无法重试，直接进死信，避免卡住队列
+		myredis.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.ZRem(ctx, ProcessingKey, payload)
+			pipe.LPush(ctx, DeadKey, payload)
+			return nil
+		})
+		return nil, err
+	}
+	return &Delivery{Job: job, Payload: payload}, nil
+}
+
+// Ack 确认任务已处理完成
+func Ack(ctx context.Context, d *Delivery) error {
+	return myredis.RedisClient.ZRem(ctx, ProcessingKey, d.Payload).Err()
+}
+
+// Fail 记录一次失败：未超过最大次数则按指数退避进入重试，否则进入死信
+func Fail(ctx context.Context, d *Delivery, cause error) error {
+	job := d.Job
+	job.Attempts++
+	job.LastError = cause.Error()
+
+	dead := job.Attempts >= MaxAttempts
+	if dead {
+		job.FailedAt = time.Now().Unix()
+	}
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	_, err = myredis.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, ProcessingKey, d.Payload)
+		if dead {
+			pipe.LPush(ctx, DeadKey, payload)
+		} else {
+			due := time.Now().Add(Backoff(job.Attempts)).UnixMilli()
+			pipe.ZAdd(ctx, RetryKey, redis.Z{Score: float64(due), Member: payload})
+		}
+		return nil
+	})
+	return err
+}
+
+// Backoff 第 n 次失败后的等待时间：2s、4s、8s ... 封顶 MaxBackoff
+func Backoff(attempts int) time.Duration {
+	if attempts <= 0 {
+		return 0
+	}
+	d := BaseBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= MaxBackoff {
+			return MaxBackoff
+		}
+	}
+	return d
+}
+
+// Reap 把超时未确认的任务和到期的重试任务放回待处理队列
+func Reap(ctx context.Context) error {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if err := reapScript.Run(ctx, myredis.RedisClient, []string{ProcessingKey, ReadyKey}, now).Err(); err != nil {
+		return err
+	}
+	return reapScript.Run(ctx, myredis.RedisClient, []string{RetryKey, ReadyKey}, now).Err()
+}
+
+// Stats 各个队列的长度
+type Stats struct {
+	Ready      int64 `json:"ready"`
+	Processing int64 `json:"processing"`
+	Retry      int64 `json:"retry"`
+	Dead       int64 `json:"dead"`
+}
+
+func GetStats(ctx context.Context) (*Stats, error) {
+	pipe := myredis.RedisClient.Pipeline()
+	ready := pipe.LLen(ctx, ReadyKey)
+	processing := pipe.ZCard(ctx, ProcessingKey)
+	retry := pipe.ZCard(ctx, RetryKey)
+	dead := pipe.LLen(ctx, DeadKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return &Stats{
+		Ready:      ready.Val(),
+		Processing: processing.Val(),
+		Retry:      retry.Val(),
+		Dead:       dead.Val(),
+	}, nil
+}
+
+// ListDead 分页查看死信（最新的在前）
+func ListDead(ctx context.Context, offset, limit int) ([]BonusJob, int64, error) {
+	total, err := myredis.RedisClient.LLen(ctx, DeadKey).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	items, err := myredis.RedisClient.LRange(ctx, DeadKey, int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	jobs := make([]BonusJob, 0, len(items))
+	for _, item := range items {
+		var job BonusJob
+		if err := json.Unmarshal([]byte(item), &job); err != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, total, nil
+}
+
+// DeadRecordIDs 死信里所有任务的 record_id，崩溃恢复跳过这些记录，由人工查看原因后重放
+func DeadRecordIDs(ctx context.Context) ([]uint, error) {
+	items, err := myredis.RedisClient.LRange(ctx, DeadKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		var job BonusJob
+		if err := json.Unmarshal([]byte(item), &job); err != nil {
+			continue
+		}
+		ids = append(ids, job.RecordID)
+	}
+	return ids, nil
+}
+
+// ReplayDead 重放死信：recordID 为 0 时重放全部，返回重放的条数
+// 重放时清空失败次数，任务重新获得完整的重试机会
+func ReplayDead(ctx context.Context, recordID uint) (int, error) {
+	items, err := myredis.RedisClient.LRange(ctx, DeadKey, 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, item := range items {
+		var job BonusJob
+		if err := json.Unmarshal([]byte(item), &job); err != nil {
+			continue
+		}
+		if recordID != 0 && job.RecordID != recordID {
+			continue
+		}
+
+		job.Attempts, job.LastError, job.FailedAt = 0, "", 0
+		payload, _ := json.Marshal(job)
+		_, err := myredis.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.LRem(ctx, DeadKey, 1, item)
+			pipe.LPush(ctx, ReadyKey, payload)
+			return nil
+		})
+		if err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
@@ -0,0 +1,41 @@
+package queue
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"test/pkg/money"
+)
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:  0,
+		1:  2 * time.Second,
+		2:  4 * time.Second,
+		3:  8 * time.Second,
+		20: MaxBackoff,
+	}
+	for attempts, want := range cases {
+		if got := Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestBonusJobLegacyAmount(t *testing.T) {
+	var job BonusJob
+	if err := json.Unmarshal([]byte(`{"record_id":7,"user_id":3,"amount":18.333333333333332,"attempts":1}`), &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.RecordID != 7 || job.UserID != 3 || job.Attempts != 1 || job.Amount != money.FromCents(1833) {
+		t.Fatalf("got %+v", job)
+	}
+
+	// 新格式往返不变
+	out, _ := json.Marshal(job)
+	var again BonusJob
+	if err := json.Unmarshal(out, &again); err != nil || again != job {
+		t.Fatalf("round trip: %s -> %+v, %v", out, again, err)
+	}
+}
@@ -0,0 +1,51 @@
+package request
+
+import "test/pkg/util"
+
+// BonusReplayReq 重放死信发奖任务，RecordID 不传表示全部重放
+type BonusReplayReq struct {
+	RecordID uint `json:"record_id" form:"record_id" label:"RecordID"`
+}
+
+// RankRebuildReq 重建排行榜，Date 为空表示当前周期
+type RankRebuildReq struct {
+	Period string `json:"period" form:"period" binding:"required,oneof=daily weekly all" label:"Period"`
+	Date   string `json:"date" form:"date" binding:"omitempty,datetime=2006-01-02" label:"Date"`
+}
+
+// DiscrepancyListReq 查询对账差异，Status 不传表示全部状态
+type DiscrepancyListReq struct {
+	util.PaginationReq
+	Status *int8 `form:"status" binding:"omitempty,oneof=0 1 2" label:"Status"`
+	GameID uint  `form:"game_id" label:"GameID"`
+}
+
+// ReconcileRunReq 手动对账，GameID 不传表示立即执行一次定时对账
+type ReconcileRunReq struct {
+	GameID uint `json:"game_id" form:"game_id" label:"GameID"`
+}
+
+// DiscrepancyIgnoreReq 人工确认差异无需处理
+type DiscrepancyIgnoreReq struct {
+	ID     uint   `json:"id" form:"id" binding:"required" label:"ID"`
+	Remark string `json:"remark" form:"remark" binding:"required,max=255" label:"Remark"`
+}
+
+// GameVoidReq 作废一局并退回全部下注
+type GameVoidReq struct {
+	GameID uint   `json:"game_id" form:"game_id" binding:"required" label:"GameID"`
+	Reason string `json:"reason" form:"reason" binding:"required,max=255" label:"Reason"`
+}
+
+// RevenueReportReq 营收报表，日期含首尾；GameType 不传表示全部游戏
+type RevenueReportReq struct {
+	GameType  int    `form:"game_type" label:"GameType"`
+	StartDate string `form:"start_date" binding:"required,datetime=2006-01-02" label:"StartDate"`
+	EndDate   string `form:"end_date" binding:"required,datetime=2006-01-02" label:"EndDate"`
+}
+
+// RevenueRebuildReq 从已结算的对局重算一段日期的营收
+type RevenueRebuildReq struct {
+	StartDate string `json:"start_date" form:"start_date" binding:"required,datetime=2006-01-02" label:"StartDate"`
+	EndDate   string `json:"end_date" form:"end_date" binding:"required,datetime=2006-01-02" label:"EndDate"`
+}
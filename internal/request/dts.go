@@ -1,7 +1,55 @@
 package request
 
+import (
+	"test/internal/service"
+	"test/pkg/money"
+)
+
 type JoinReq struct {
-	GameID int     `json:"game_id" form:"game_id" binding:"required" label:"GameID"`
-	RoomID int     `json:"room_id" form:"room_id" binding:"required" label:"RoomID"`
-	Amount float64 `json:"amount" form:"amount" binding:"required" label:"Amount"`
+	GameID int         `json:"game_id" form:"game_id" binding:"required" label:"GameID"`
+	RoomID int         `json:"room_id" form:"room_id" binding:"required,min=1" label:"RoomID"`
+	Amount money.Money `json:"amount" form:"amount" binding:"required,gt=0" label:"Amount"`
+	// ClientSeed 可选，玩家自己的随机种子，用于可证明公平
+	ClientSeed string `json:"client_seed" form:"client_seed" binding:"max=64" label:"ClientSeed"`
+}
+
+// ToBet 转换为下注参数
+func (r JoinReq) ToBet(userID int64) service.DtsBetReq {
+	return service.DtsBetReq{
+		GameID:     uint(r.GameID),
+		UserID:     userID,
+		RoomID:     r.RoomID,
+		Amount:     r.Amount,
+		ClientSeed: r.ClientSeed,
+	}
+}
+
+// SwitchRoomReq 换房：把本局已有的下注整体换到另一个房间
+type SwitchRoomReq struct {
+	GameID int `json:"game_id" form:"game_id" binding:"required" label:"GameID"`
+	RoomID int `json:"room_id" form:"room_id" binding:"required,min=1" label:"RoomID"`
+}
+
+// ToSwitch 转换为换房参数
+func (r SwitchRoomReq) ToSwitch(userID int64) service.DtsSwitchReq {
+	return service.DtsSwitchReq{
+		GameID: uint(r.GameID),
+		UserID: userID,
+		RoomID: r.RoomID,
+	}
+}
+
+// QuitReq 退出（WebSocket 指令的参数，HTTP 接口仍从 query 读取）
+type QuitReq struct {
+	GameID int64 `json:"game_id" binding:"required" label:"GameID"`
+}
+
+type VerifyReq struct {
+	GameID uint `json:"game_id" form:"game_id" binding:"required" label:"GameID"`
+}
+
+// RankReq 排行榜查询
+type RankReq struct {
+	Period string `form:"period" binding:"omitempty,oneof=daily weekly all" label:"Period"` // 默认 daily
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100" label:"Limit"`            // 默认 10
 }
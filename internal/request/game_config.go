@@ -0,0 +1,94 @@
+package request
+
+import (
+	"github.com/shopspring/decimal"
+	"test/internal/model"
+	"test/pkg/money"
+	"test/pkg/util"
+)
+
+// GameConfigQueryReq 查询玩法配置
+type GameConfigQueryReq struct {
+	util.PaginationReq
+	GameType int `form:"game_type" binding:"required" label:"GameType"`
+}
+
+// GameConfigReq 发布新版本配置，没传的字段沿用当前版本
+type GameConfigReq struct {
+	GameType  int      `json:"game_type" form:"game_type" binding:"required" label:"GameType"`
+	MaxPeople *int     `json:"max_people" form:"max_people" binding:"omitempty,min=1,max=1000" label:"MaxPeople"`
+	Duration  *int     `json:"duration" form:"duration" binding:"omitempty,min=5,max=3600" label:"Duration"`
+	RoomCount *int     `json:"room_count" form:"room_count" binding:"omitempty,min=2,max=99" label:"RoomCount"`
+	RakeRate  *float64 `json:"rake_rate" form:"rake_rate" binding:"omitempty,gte=0,lt=1" label:"RakeRate"` // 派奖比例随之改为 1 - RakeRate
+	// PayoutRate 兼容还在传派奖比例的管理后台，换算成 RakeRate = 1 - PayoutRate；同时传了 rake_rate 时以 rake_rate 为准
+	PayoutRate *float64 `json:"payout_rate" form:"payout_rate" binding:"omitempty,gt=0,lte=1" label:"PayoutRate"`
+	// 下注限制，传 0 表示取消限制
+	MinBet          *money.Money `json:"min_bet" form:"min_bet" binding:"omitempty,min=0" label:"MinBet"`
+	MaxBet          *money.Money `json:"max_bet" form:"max_bet" binding:"omitempty,min=0" label:"MaxBet"`
+	MaxUserTotal    *money.Money `json:"max_user_total" form:"max_user_total" binding:"omitempty,min=0" label:"MaxUserTotal"`
+	MaxRoomTotal    *money.Money `json:"max_room_total" form:"max_room_total" binding:"omitempty,min=0" label:"MaxRoomTotal"`
+	MaxBetsPerRound *int         `json:"max_bets_per_round" form:"max_bets_per_round" binding:"omitempty,min=0" label:"MaxBetsPerRound"`
+	// 换房规则
+	MaxSwitches       *int         `json:"max_switches" form:"max_switches" binding:"omitempty,min=-1" label:"MaxSwitches"`
+	SwitchLockSeconds *int         `json:"switch_lock_seconds" form:"switch_lock_seconds" binding:"omitempty,min=0,max=3600" label:"SwitchLockSeconds"`
+	SwitchFee         *money.Money `json:"switch_fee" form:"switch_fee" binding:"omitempty,min=0" label:"SwitchFee"`
+	// 人数不够时的等待超时
+	MaxWaitSeconds    *int    `json:"max_wait_seconds" form:"max_wait_seconds" binding:"omitempty,min=0,max=86400" label:"MaxWaitSeconds"`
+	WaitTimeoutPolicy *string `json:"wait_timeout_policy" form:"wait_timeout_policy" binding:"omitempty,oneof=refund start" label:"WaitTimeoutPolicy"`
+	Remark            string  `json:"remark" form:"remark" binding:"required,max=255" label:"Remark"`
+}
+
+// Apply 把请求里传了的字段覆盖到配置上
+func (r *GameConfigReq) Apply(cfg *model.GameConfig) {
+	if r.MaxPeople != nil {
+		cfg.MaxPeople = *r.MaxPeople
+	}
+	if r.Duration != nil {
+		cfg.Duration = *r.Duration
+	}
+	if r.RoomCount != nil {
+		cfg.RoomCount = *r.RoomCount
+	}
+	// 两个比例都是 4 位小数，用 decimal 相减避免 1 - 0.1 这样的浮点误差
+	switch {
+	case r.RakeRate != nil:
+		rake := decimal.NewFromFloat(*r.RakeRate).Round(4)
+		cfg.RakeRate = rake.InexactFloat64()
+		cfg.PayoutRate = decimal.NewFromInt(1).Sub(rake).InexactFloat64()
+	case r.PayoutRate != nil:
+		payout := decimal.NewFromFloat(*r.PayoutRate).Round(4)
+		cfg.PayoutRate = payout.InexactFloat64()
+		cfg.RakeRate = decimal.NewFromInt(1).Sub(payout).InexactFloat64()
+	}
+	if r.MinBet != nil {
+		cfg.MinBet = *r.MinBet
+	}
+	if r.MaxBet != nil {
+		cfg.MaxBet = *r.MaxBet
+	}
+	if r.MaxUserTotal != nil {
+		cfg.MaxUserTotal = *r.MaxUserTotal
+	}
+	if r.MaxRoomTotal != nil {
+		cfg.MaxRoomTotal = *r.MaxRoomTotal
+	}
+	if r.MaxBetsPerRound != nil {
+		cfg.MaxBetsPerRound = *r.MaxBetsPerRound
+	}
+	if r.MaxSwitches != nil {
+		cfg.MaxSwitches = *r.MaxSwitches
+	}
+	if r.SwitchLockSeconds != nil {
+		cfg.SwitchLockSeconds = *r.SwitchLockSeconds
+	}
+	if r.SwitchFee != nil {
+		cfg.SwitchFee = *r.SwitchFee
+	}
+	if r.MaxWaitSeconds != nil {
+		cfg.MaxWaitSeconds = *r.MaxWaitSeconds
+	}
+	if r.WaitTimeoutPolicy != nil {
+		cfg.WaitTimeoutPolicy = *r.WaitTimeoutPolicy
+	}
+	cfg.Remark = r.Remark
+}
@@ -0,0 +1,43 @@
+package request
+
+import "test/pkg/money"
+
+// GamingLimitReq 修改负责任博彩限制，没传的字段保持不变，传 0 表示取消该项限制
+// 收紧立即生效，放宽（调高或取消）要等待一段时间后才生效
+type GamingLimitReq struct {
+	DailyWager          *money.Money `json:"daily_wager" form:"daily_wager" binding:"omitempty,min=0" label:"DailyWager"`
+	WeeklyWager         *money.Money `json:"weekly_wager" form:"weekly_wager" binding:"omitempty,min=0" label:"WeeklyWager"`
+	MonthlyWager        *money.Money `json:"monthly_wager" form:"monthly_wager" binding:"omitempty,min=0" label:"MonthlyWager"`
+	DailyLoss           *money.Money `json:"daily_loss" form:"daily_loss" binding:"omitempty,min=0" label:"DailyLoss"`
+	WeeklyLoss          *money.Money `json:"weekly_loss" form:"weekly_loss" binding:"omitempty,min=0" label:"WeeklyLoss"`
+	MonthlyLoss         *money.Money `json:"monthly_loss" form:"monthly_loss" binding:"omitempty,min=0" label:"MonthlyLoss"`
+	RealityCheckMinutes *int         `json:"reality_check_minutes" form:"reality_check_minutes" binding:"omitempty,min=0,max=1440" label:"RealityCheckMinutes"`
+}
+
+// Values 传了的金额上限，key 与审计记录的 field 一致
+func (r *GamingLimitReq) Values() map[string]money.Money {
+	values := make(map[string]money.Money)
+	for name, value := range map[string]*money.Money{
+		"daily_wager":   r.DailyWager,
+		"weekly_wager":  r.WeeklyWager,
+		"monthly_wager": r.MonthlyWager,
+		"daily_loss":    r.DailyLoss,
+		"weekly_loss":   r.WeeklyLoss,
+		"monthly_loss":  r.MonthlyLoss,
+	} {
+		if value != nil {
+			values[name] = *value
+		}
+	}
+	return values
+}
+
+// CoolOffReq 冷静期天数
+type CoolOffReq struct {
+	Days int `json:"days" form:"days" binding:"required,oneof=1 7 30" label:"Days"`
+}
+
+// SelfExcludeReq 自我排除天数，0 表示永久
+type SelfExcludeReq struct {
+	Days *int `json:"days" form:"days" binding:"required,oneof=0 180 365 1825" label:"Days"`
+}
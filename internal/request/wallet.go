@@ -0,0 +1,19 @@
+package request
+
+import (
+	"test/pkg/money"
+	"test/pkg/util"
+)
+
+// WalletListReq 用户流水查询
+type WalletListReq struct {
+	util.PaginationReq
+	Type string `form:"type" binding:"omitempty,oneof=bet payout refund adjustment switch_fee reversal" label:"Type"`
+}
+
+// WalletAdjustReq 后台调账
+type WalletAdjustReq struct {
+	UserID int64       `json:"user_id" form:"user_id" binding:"required" label:"UserID"`
+	Amount money.Money `json:"amount" form:"amount" binding:"required" label:"Amount"` // 正数加款，负数扣款
+	Remark string      `json:"remark" form:"remark" binding:"required,max=255" label:"Remark"`
+}
@@ -0,0 +1,79 @@
+package serializer
+
+import (
+	"test/internal/model"
+	"test/pkg/money"
+	"test/pkg/util"
+)
+
+// DtsGameResp 已结算的一局
+type DtsGameResp struct {
+	ID                uint           `json:"id"`
+	KillerRoom        int64          `json:"killer_room"`
+	PreKillerRoom     int64          `json:"pre_killer_room"`
+	TotalPeople       int64          `json:"total_people"`
+	TotalAmount       money.Money    `json:"total_amount" swaggertype:"number"`
+	TotalBonus        money.Money    `json:"total_bonus" swaggertype:"number"`
+	TotalKillerAmount money.Money    `json:"total_killer_amount" swaggertype:"number"`
+	StartTime         int64          `json:"start_time"`
+	EndTime           int64          `json:"end_time"`
+	ServerSeedHash    string         `json:"server_seed_hash"`
+	CreatedAt         util.LocalTime `json:"created_at"`
+}
+
+func BuildDtsGame(item model.LmDtsGame) DtsGameResp {
+	return DtsGameResp{
+		ID:                item.ID,
+		KillerRoom:        item.KillerRoom,
+		PreKillerRoom:     item.PreKillerRoom,
+		TotalPeople:       item.TotalPeople,
+		TotalAmount:       item.TotalAmount,
+		TotalBonus:        item.TotalBonus,
+		TotalKillerAmount: item.TotalKillerAmount,
+		StartTime:         item.StartTime,
+		EndTime:           item.EndTime,
+		ServerSeedHash:    item.ServerSeedHash,
+		CreatedAt:         util.LocalTime(item.CreatedAt),
+	}
+}
+
+func BuildDtsGames(items []model.LmDtsGame) []DtsGameResp {
+	list := make([]DtsGameResp, 0, len(items))
+	for _, item := range items {
+		list = append(list, BuildDtsGame(item))
+	}
+	return list
+}
+
+// DtsRecordResp 用户的一次下注
+type DtsRecordResp struct {
+	ID         uint           `json:"id"`
+	GameID     int64          `json:"game_id"`
+	RoomID     int64          `json:"room_id"`
+	Amount     money.Money    `json:"amount" swaggertype:"number"`
+	State      int8           `json:"state"` // 0:等待 1:胜 2:负 3:作废
+	KillerRoom int64          `json:"killer_room"`
+	Bonus      money.Money    `json:"bonus" swaggertype:"number"`
+	CreatedAt  util.LocalTime `json:"created_at"`
+}
+
+func BuildDtsRecord(item model.LmDtsRecord) DtsRecordResp {
+	return DtsRecordResp{
+		ID:         item.ID,
+		GameID:     item.GameId,
+		RoomID:     item.RoomId,
+		Amount:     item.Amount,
+		State:      item.State,
+		KillerRoom: item.KillerRoom,
+		Bonus:      item.Bonus,
+		CreatedAt:  util.LocalTime(item.CreatedAt),
+	}
+}
+
+func BuildDtsRecords(items []model.LmDtsRecord) []DtsRecordResp {
+	list := make([]DtsRecordResp, 0, len(items))
+	for _, item := range items {
+		list = append(list, BuildDtsRecord(item))
+	}
+	return list
+}
@@ -0,0 +1,51 @@
+package serializer
+
+import (
+	"test/internal/model"
+	"test/pkg/money"
+	"test/pkg/util"
+)
+
+// WalletTransactionDataList 用于 API 文档的分页包装
+type WalletTransactionDataList struct {
+	Items []WalletTransactionResp `json:"items"`
+	Total int64                   `json:"total"`
+	Page  int                     `json:"page"`
+	Size  int                     `json:"size"`
+}
+
+type WalletTransactionResp struct {
+	ID            uint           `json:"id"`
+	TxNo          string         `json:"tx_no"`
+	Type          string         `json:"type"`
+	Amount        money.Money    `json:"amount" swaggertype:"number"`
+	BalanceBefore money.Money    `json:"balance_before" swaggertype:"number"`
+	BalanceAfter  money.Money    `json:"balance_after" swaggertype:"number"`
+	RefType       string         `json:"ref_type"`
+	RefId         int64          `json:"ref_id"`
+	Remark        string         `json:"remark"`
+	CreatedAt     util.LocalTime `json:"created_at"`
+}
+
+func BuildWalletTransaction(item model.WalletTransaction) WalletTransactionResp {
+	return WalletTransactionResp{
+		ID:            item.ID,
+		TxNo:          item.TxNo,
+		Type:          item.Type,
+		Amount:        item.Amount,
+		BalanceBefore: item.BalanceBefore,
+		BalanceAfter:  item.BalanceAfter,
+		RefType:       item.RefType,
+		RefId:         item.RefId,
+		Remark:        item.Remark,
+		CreatedAt:     util.LocalTime(item.CreatedAt),
+	}
+}
+
+func BuildWalletTransactions(items []model.WalletTransaction) []WalletTransactionResp {
+	list := make([]WalletTransactionResp, 0, len(items))
+	for _, item := range items {
+		list = append(list, BuildWalletTransaction(item))
+	}
+	return list
+}
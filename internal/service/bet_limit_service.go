@@ -0,0 +1,103 @@
+package service
+
+import (
+	"gorm.io/gorm"
+	"test/internal/model"
+	"test/pkg/money"
+	"test/pkg/util"
+)
+
+// BetLimitState 校验一次下注时本局的现状
+type BetLimitState struct {
+	RoomID    int
+	Amount    money.Money // 本次下注金额
+	UserTotal money.Money // 该玩家本局已经下注的总额（不含本次）
+	UserBets  int64       // 该玩家本局已经下注的次数（不含本次）
+	RoomTotal money.Money // 房间里其他玩家的下注总额
+}
+
+// CheckBetLimits 按本局配置校验下注限制，限制值为 0 表示不限制
+// 同一局再次下注是在原房间追加，房间总额要算上玩家之前的下注
+func CheckBetLimits(cfg *model.GameConfig, s BetLimitState) error {
+	if s.RoomID < 1 || s.RoomID > cfg.RoomCount {
+		return util.NewBizErr("BetInvalidRoom", map[string]interface{}{"Max": cfg.RoomCount})
+	}
+
+	if !s.Amount.IsPositive() {
+		return util.NewBizErr("InvalidAmount", nil)
+	}
+	if cfg.MinBet > 0 && s.Amount < cfg.MinBet {
+		return util.NewBizErr("BetBelowMin", map[string]interface{}{"Min": cfg.MinBet})
+	}
+	if cfg.MaxBet > 0 && s.Amount > cfg.MaxBet {
+		return util.NewBizErr("BetAboveMax", map[string]interface{}{"Max": cfg.MaxBet})
+	}
+	if cfg.MaxBetsPerRound > 0 && s.UserBets >= int64(cfg.MaxBetsPerRound) {
+		return util.NewBizErr("BetTooManyTimes", map[string]interface{}{"Max": cfg.MaxBetsPerRound})
+	}
+
+	userTotal := s.UserTotal + s.Amount
+	if cfg.MaxUserTotal > 0 && userTotal > cfg.MaxUserTotal {
+		return util.NewBizErr("BetUserTotalExceeded", map[string]interface{}{"Max": cfg.MaxUserTotal})
+	}
+	if cfg.MaxRoomTotal > 0 && s.RoomTotal+userTotal > cfg.MaxRoomTotal {
+		return util.NewBizErr("BetRoomTotalExceeded", map[string]interface{}{"Max": cfg.MaxRoomTotal})
+	}
+	return nil
+}
+
+// ValidateBetLimitConfig 发布配置前检查下注限制之间是否自相矛盾（0 表示不限制，不参与比较）
+// 例如下限大于上限时任何下注都会被拒绝
+func ValidateBetLimitConfig(cfg *model.GameConfig) error {
+	if cfg.MinBet > 0 && cfg.MaxBet > 0 && cfg.MinBet > cfg.MaxBet {
+		return util.NewBizErr("BetLimitMinAboveMax", map[string]interface{}{"Min": cfg.MinBet, "Max": cfg.MaxBet})
+	}
+	if cfg.MaxBet > 0 && cfg.MaxUserTotal > 0 && cfg.MaxBet > cfg.MaxUserTotal {
+		return util.NewBizErr("BetLimitMaxAboveUserTotal", map[string]interface{}{"Max": cfg.MaxBet, "Total": cfg.MaxUserTotal})
+	}
+	return nil
+}
+
+// CheckDtsBetLimits 在下注事务里读取本局现状并校验下注限制，调用前需要用 LockDtsGame 锁住游戏行
+func CheckDtsBetLimits(tx *gorm.DB, dtsGame *model.LmDtsGame, userID int64, roomID int, amount money.Money) error {
+	cfg, err := GetDtsGameConfig(tx.Statement.Context, dtsGame)
+	if err != nil {
+		return err
+	}
+
+	state := BetLimitState{RoomID: roomID, Amount: amount}
+
+	var records []model.LmDtsRecord
+	if err := tx.Where("user_id = ? AND game_id = ?", userID, dtsGame.ID).Limit(1).Find(&records).Error; err != nil {
+		return err
+	}
+	if len(records) > 0 {
+		state.UserTotal = records[0].Amount
+		// 追加下注只更新同一条记录，下注次数以扣款流水为准
+		if err := tx.Model(&model.WalletTransaction{}).
+			Where("user_id = ? AND type = ? AND ref_type = ? AND ref_id = ?", userID, TxTypeBet, RefTypeRecord, records[0].ID).
+			Count(&state.UserBets).Error; err != nil {
+			return err
+		}
+	}
+
+	if cfg.MaxRoomTotal > 0 {
+		if state.RoomTotal, err = otherRoomTotal(tx, dtsGame.ID, roomID, userID); err != nil {
+			return err
+		}
+	}
+
+	return CheckBetLimits(cfg, state)
+}
+
+// otherRoomTotal 房间里其他玩家的下注总额
+// 房间总额是多个玩家共同的限制，调用方必须已经用 LockDtsGame 锁住游戏行，同一局的下注 / 换房串行执行才不会一起超限
+func otherRoomTotal(tx *gorm.DB, gameID uint, roomID int, userID int64) (money.Money, error) {
+	var roomTotal money.Money
+	if err := tx.Model(&model.LmDtsRecord{}).
+		Where("game_id = ? AND room_id = ? AND user_id <> ?", gameID, roomID, userID).
+		Select("SUM(amount)").Row().Scan(&roomTotal); err != nil {
+		return 0, err
+	}
+	return roomTotal, nil
+}
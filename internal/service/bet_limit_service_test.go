@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"test/internal/model"
+	"test/pkg/money"
+	"test/pkg/util"
+)
+
+func TestCheckBetLimits(t *testing.T) {
+	cfg := &model.GameConfig{
+		RoomCount:       9,
+		MinBet:          money.FromYuan(1),
+		MaxBet:          money.FromYuan(100),
+		MaxUserTotal:    money.FromYuan(150),
+		MaxRoomTotal:    money.FromYuan(300),
+		MaxBetsPerRound: 3,
+	}
+
+	cases := []struct {
+		name  string
+		state BetLimitState
+		want  string
+	}{
+		{"ok", BetLimitState{RoomID: 1, Amount: money.FromYuan(50)}, ""},
+		{"room zero", BetLimitState{RoomID: 0, Amount: money.FromYuan(50)}, "BetInvalidRoom"},
+		{"room out of range", BetLimitState{RoomID: 42, Amount: money.FromYuan(50)}, "BetInvalidRoom"},
+		{"negative", BetLimitState{RoomID: 1, Amount: -1}, "InvalidAmount"},
+		{"below min", BetLimitState{RoomID: 1, Amount: money.FromCents(50)}, "BetBelowMin"},
+		{"above max", BetLimitState{RoomID: 1, Amount: money.FromCents(10001)}, "BetAboveMax"},
+		{"too many bets", BetLimitState{RoomID: 1, Amount: money.FromYuan(10), UserBets: 3}, "BetTooManyTimes"},
+		{"user total", BetLimitState{RoomID: 1, Amount: money.FromYuan(60), UserTotal: money.FromYuan(100), UserBets: 1}, "BetUserTotalExceeded"},
+		{"user total exact", BetLimitState{RoomID: 1, Amount: money.FromYuan(50), UserTotal: money.FromYuan(100), UserBets: 1}, ""},
+		// 追加下注时玩家之前的下注也算在房间总额里
+		{"room total", BetLimitState{RoomID: 2, Amount: money.FromYuan(10), UserTotal: money.FromYuan(100), RoomTotal: money.FromYuan(200)}, "BetRoomTotalExceeded"},
+		{"room total exact", BetLimitState{RoomID: 2, Amount: money.FromYuan(10), UserTotal: money.FromYuan(90), RoomTotal: money.FromYuan(200)}, ""},
+	}
+	for _, c := range cases {
+		err := CheckBetLimits(cfg, c.state)
+		var bizErr *util.BizError
+		switch {
+		case c.want == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		case c.want != "" && (!errors.As(err, &bizErr) || bizErr.Key != c.want):
+			t.Errorf("%s: want %s, got %v", c.name, c.want, err)
+		}
+	}
+
+	// 0 表示不限制，只校验房间范围
+	unlimited := &model.GameConfig{RoomCount: 9}
+	if err := CheckBetLimits(unlimited, BetLimitState{RoomID: 9, Amount: money.FromYuan(1e9), UserTotal: money.FromYuan(1e9), UserBets: 1000, RoomTotal: money.FromYuan(1e9)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateBetLimitConfig(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  model.GameConfig
+		want string
+	}{
+		{"ok", model.GameConfig{MinBet: money.FromYuan(1), MaxBet: money.FromYuan(100), MaxUserTotal: money.FromYuan(100)}, ""},
+		{"min above max", model.GameConfig{MinBet: money.FromYuan(10), MaxBet: money.FromYuan(5)}, "BetLimitMinAboveMax"},
+		{"max above user total", model.GameConfig{MaxBet: money.FromYuan(100), MaxUserTotal: money.FromYuan(50)}, "BetLimitMaxAboveUserTotal"},
+		// 0 表示不限制
+		{"unlimited max", model.GameConfig{MinBet: money.FromYuan(10), MaxUserTotal: money.FromYuan(50)}, ""},
+		{"unlimited user total", model.GameConfig{MinBet: money.FromYuan(10), MaxBet: money.FromYuan(500)}, ""},
+	}
+	for _, c := range cases {
+		if got := bizKey(ValidateBetLimitConfig(&c.cfg)); got != c.want {
+			t.Errorf("%s: want %q, got %q", c.name, c.want, got)
+		}
+	}
+}
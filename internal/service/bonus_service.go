@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+
+	"test/internal/queue"
+	"test/pkg/util"
+)
+
+// BonusQueueStats 发奖队列各阶段的积压情况
+func BonusQueueStats(ctx context.Context) (*queue.Stats, error) {
+	stats, err := queue.GetStats(ctx)
+	if err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+	return stats, nil
+}
+
+// ListDeadBonusJobs 分页查看死信任务
+func ListDeadBonusJobs(ctx context.Context, req util.PaginationReq) ([]queue.BonusJob, int64, error) {
+	jobs, total, err := queue.ListDead(ctx, req.GetOffset(), req.GetSize())
+	if err != nil {
+		return nil, 0, util.NewBizErr("SystemBusy", nil)
+	}
+	return jobs, total, nil
+}
+
+// ReplayDeadBonusJobs 重放死信任务，recordID 为 0 表示全部重放
+// 派奖按 record_id 幂等，重放已经成功过的任务也不会重复加钱
+func ReplayDeadBonusJobs(ctx context.Context, recordID uint) (int, error) {
+	replayed, err := queue.ReplayDead(ctx, recordID)
+	if err != nil {
+		return replayed, util.NewBizErr("SystemBusy", nil)
+	}
+	return replayed, nil
+}
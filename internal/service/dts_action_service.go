@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"test/internal/event"
+	"test/internal/game"
+	"test/internal/model"
+	"test/pkg/database"
+	"test/pkg/money"
+	"test/pkg/util"
+)
+
+// 大逃杀的玩家操作，HTTP 接口和 WebSocket 指令共用
+
+// DtsInitResult 进入游戏的返回
+type DtsInitResult struct {
+	Balance money.Money `json:"balance"`
+	GameID  uint        `json:"game_id"`
+	UserID  int64       `json:"user_id"`
+}
+
+// InitDts 进入当前这一局（只加入观战列表，不下注）
+func InitDts(ctx context.Context, userID int64) (*DtsInitResult, error) {
+	var user model.User
+	if err := database.DB.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, util.NewBizErr("用户获取错误", nil)
+	}
+
+	// 获取当前游戏
+	var dtsGame model.LmDtsGame
+	if err := database.DB.WithContext(ctx).Order("id desc").First(&dtsGame).Error; err != nil {
+		return nil, util.NewBizErr("当前没有正在进行的游戏", nil)
+	}
+
+	req := JoinGameReq{
+		GameID:   dtsGame.ID,
+		UserID:   userID,
+		RoomID:   0, // 默认进 1 号房
+		Amount:   0, // 默认下注 0
+		Nickname: "New Player",
+	}
+
+	// 调用逻辑，直接拿回 Redis 的数据
+	if _, err := JoinUserList(ctx, req); err != nil {
+		return nil, util.NewBizErr("操作失败", nil)
+	}
+
+	return &DtsInitResult{
+		Balance: user.Amount,
+		GameID:  dtsGame.ID,
+		UserID:  userID,
+	}, nil
+}
+
+// QuitDts 退出某一局的用户列表
+func QuitDts(ctx context.Context, gameID, userID int64) error {
+	var dtsGame model.LmDtsGame
+	if err := database.DB.WithContext(ctx).Where("id = ?", gameID).First(&dtsGame).Error; err != nil {
+		return util.NewBizErr("当前游戏不存在", nil)
+	}
+
+	if err := RemoveUserList(ctx, gameID, userID); err != nil {
+		return util.NewBizErr("操作失败", nil)
+	}
+	return nil
+}
+
+// DtsBetReq 一次下注
+type DtsBetReq struct {
+	GameID     uint
+	UserID     int64
+	RoomID     int
+	Amount     money.Money
+	ClientSeed string
+}
+
+// PlaceDtsBet 下注（同一局再次下注为在原房间追加金额）
+func PlaceDtsBet(ctx context.Context, bet DtsBetReq) error {
+	engine, ok := game.Get(game.TypeDts)
+	if !ok {
+		return util.NewBizErr("GameNotFound", nil)
+	}
+
+	var dtsGame model.LmDtsGame
+	var countdownStarted bool
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 1. 玩法校验：结算锁、游戏状态、金额等
+		if err := engine.ValidateBet(ctx, tx, game.Bet{
+			GameID: bet.GameID,
+			UserID: bet.UserID,
+			RoomID: bet.RoomID,
+			Amount: bet.Amount,
+		}); err != nil {
+			return err
+		}
+
+		if err := tx.First(&dtsGame, bet.GameID).Error; err != nil {
+			return err
+		}
+
+		// 事务内：先锁住用户行，串行化同一用户的并发下注（核心屏障）
+		var user model.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, bet.UserID).Error; err != nil {
+			return err
+		}
+
+		// 负责任博彩：自我排除、冷静期、各周期的下注 / 亏损上限（用户行已锁，统计不会被并发下注绕过）
+		if err := CheckGamingLimits(tx, bet.UserID, bet.Amount); err != nil {
+			return err
+		}
+
+		// 2. 处理下注记录 (Upsert 逻辑)
+		var record model.LmDtsRecord
+		result := tx.Where("user_id = ? AND game_id = ?", bet.UserID, dtsGame.ID).First(&record)
+
+		var newTotalAmount money.Money
+		if result.Error == nil {
+			// 已有记录：只在原房间追加金额，换房走 SwitchDtsRoom
+			if record.RoomId != int64(bet.RoomID) {
+				return util.NewBizErr("BetRoomMismatch", map[string]interface{}{"Room": record.RoomId})
+			}
+			newTotalAmount = record.Amount + bet.Amount
+			if err := tx.Model(&record).Update("amount", newTotalAmount).Error; err != nil {
+				return err
+			}
+		} else if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+
+			newTotalAmount = bet.Amount
+			// 无记录：创建新记录
+			record = model.LmDtsRecord{
+				GameId:     int64(bet.GameID),
+				UserId:     bet.UserID,
+				RoomId:     int64(bet.RoomID),
+				Amount:     bet.Amount,
+				ClientSeed: bet.ClientSeed,
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+		} else {
+			return result.Error
+		}
+
+		// 3. 扣除用户余额并记账（内部对用户行加悲观锁，余额不足会回滚整个事务）
+		if _, err := ChangeBalance(tx, WalletChange{
+			UserID:  bet.UserID,
+			Type:    TxTypeBet,
+			Amount:  -bet.Amount,
+			RefType: RefTypeRecord,
+			RefID:   int64(record.ID),
+		}); err != nil {
+			return err
+		}
+
+		started, err := UpdateGame(tx, &dtsGame)
+		if err != nil {
+			return err
+		}
+		countdownStarted = started
+
+		return AddUserList(ctx, &JoinGameReq{
+			GameID:   dtsGame.ID,
+			UserID:   bet.UserID,
+			RoomID:   bet.RoomID,
+			Amount:   newTotalAmount,
+			Nickname: "New Player",
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// 事务提交后再发事件，推送读到的一定是已落库的数据
+	event.Publish(event.Event{
+		Type:     event.BetPlaced,
+		GameType: game.TypeDts,
+		GameID:   dtsGame.ID,
+		UserID:   bet.UserID,
+		Data:     map[string]interface{}{"room_id": bet.RoomID, "amount": bet.Amount},
+	})
+	if countdownStarted {
+		event.Publish(event.Event{
+			Type:     event.CountdownStarted,
+			GameType: game.TypeDts,
+			GameID:   dtsGame.ID,
+			Data:     map[string]interface{}{"start_time": dtsGame.StartTime, "end_time": dtsGame.EndTime},
+		})
+	}
+	return nil
+}
+
+// DtsSwitchReq 一次换房
+type DtsSwitchReq struct {
+	GameID uint
+	UserID int64
+	RoomID int
+}
+
+// SwitchDtsRoom 把玩家本局的全部下注换到另一个房间
+// 按本局配置校验换房次数、倒计时末尾的锁定时间和目标房间的总额上限，有手续费时从余额扣除
+func SwitchDtsRoom(ctx context.Context, req DtsSwitchReq) error {
+	var fromRoom int64
+	var fee money.Money
+	var record model.LmDtsRecord
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 加锁顺序与下注一致：游戏行 → 用户行 → 下注记录，避免和并发下注互相死锁
+		dtsGame, err := LockDtsGame(tx, req.GameID, time.Now())
+		if err != nil {
+			return err
+		}
+		cfg, err := GetDtsGameConfig(ctx, dtsGame)
+		if err != nil {
+			return err
+		}
+
+		var roomTotal money.Money
+		if cfg.MaxRoomTotal > 0 {
+			if roomTotal, err = otherRoomTotal(tx, dtsGame.ID, req.RoomID, req.UserID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&model.User{}, req.UserID).Error; err != nil {
+			return err
+		}
+
+		err = tx.Where("user_id = ? AND game_id = ?", req.UserID, dtsGame.ID).First(&record).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return util.NewBizErr("SwitchNoBet", nil)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := CheckSwitchRules(cfg, dtsGame, &record, req.RoomID, time.Now()); err != nil {
+			return err
+		}
+		if cfg.MaxRoomTotal > 0 {
+			if roomTotal+record.Amount > cfg.MaxRoomTotal {
+				return util.NewBizErr("BetRoomTotalExceeded", map[string]interface{}{"Max": cfg.MaxRoomTotal})
+			}
+		}
+
+		// 自我排除、冷静期期间同样不能换房；手续费计入各周期的上限
+		fee = cfg.SwitchFee
+		if err := CheckGamingLimits(tx, req.UserID, fee); err != nil {
+			return err
+		}
+		if fee > 0 {
+			if _, err := ChangeBalance(tx, WalletChange{
+				UserID:  req.UserID,
+				Type:    TxTypeSwitchFee,
+				Amount:  -fee,
+				RefType: RefTypeRecord,
+				RefID:   int64(record.ID),
+			}); err != nil {
+				return err
+			}
+		}
+
+		fromRoom = record.RoomId
+		return tx.Model(&record).Updates(map[string]interface{}{
+			"room_id":      req.RoomID,
+			"switch_count": gorm.Expr("switch_count + 1"),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// 事务提交之后再改用户列表：在事务里写 Redis，提交失败时缓存里就是一次没有发生的换房
+	// 这里写失败只影响展示，换房本身已经生效，玩家下一次下注或换房会重新写入
+	if err := AddUserList(ctx, &JoinGameReq{
+		GameID:   req.GameID,
+		UserID:   req.UserID,
+		RoomID:   req.RoomID,
+		Amount:   record.Amount,
+		Nickname: "New Player",
+	}); err != nil {
+		fmt.Printf("换房后更新用户列表失败 game_id=%d user_id=%d: %v\n", req.GameID, req.UserID, err)
+	}
+
+	event.Publish(event.Event{
+		Type:     event.RoomSwitched,
+		GameType: game.TypeDts,
+		GameID:   req.GameID,
+		UserID:   req.UserID,
+		Data:     map[string]interface{}{"from_room": int(fromRoom), "room_id": req.RoomID, "fee": fee},
+	})
+	return nil
+}
+
+// LockDtsGame 下注 / 换房事务里锁住游戏行并确认这一局还能操作
+// 结算和作废同样先锁游戏行，结算在行锁内重新读取下注记录，所以这里提交的修改不会被漏掉或覆盖
+func LockDtsGame(tx *gorm.DB, gameID uint, now time.Time) (*model.LmDtsGame, error) {
+	var dtsGame model.LmDtsGame
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&dtsGame, gameID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, util.NewBizErr("GameNotFound", nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckDtsGameOpen(&dtsGame, now); err != nil {
+		return nil, err
+	}
+	return &dtsGame, nil
+}
+
+// CheckDtsGameOpen 只有等人（state 1）和倒计时还没结束的局（state 2）可以下注、换房
+// 倒计时结束后到结算之前 state 仍是 2，这段时间同样拒绝
+func CheckDtsGameOpen(dtsGame *model.LmDtsGame, now time.Time) error {
+	switch {
+	case dtsGame.State >= 3:
+		return util.NewBizErr("GameEnded", nil)
+	case dtsGame.State == 2 && dtsGame.EndTime <= now.Unix():
+		return util.NewBizErr("GameSettling", nil)
+	}
+	return nil
+}
+
+// CheckSwitchRules 换房规则：这一局还能操作、目标房间有效且不同于当前房间、次数未超限、不在倒计时末尾的锁定时间内
+func CheckSwitchRules(cfg *model.GameConfig, dtsGame *model.LmDtsGame, record *model.LmDtsRecord, roomID int, now time.Time) error {
+	if err := CheckDtsGameOpen(dtsGame, now); err != nil {
+		return err
+	}
+	if cfg.MaxSwitches < 0 {
+		return util.NewBizErr("SwitchDisabled", nil)
+	}
+	if roomID < 1 || roomID > cfg.RoomCount {
+		return util.NewBizErr("BetInvalidRoom", map[string]interface{}{"Max": cfg.RoomCount})
+	}
+	if record.RoomId == int64(roomID) {
+		return util.NewBizErr("SwitchSameRoom", nil)
+	}
+	if cfg.MaxSwitches > 0 && record.SwitchCount >= cfg.MaxSwitches {
+		return util.NewBizErr("SwitchLimitReached", map[string]interface{}{"Max": cfg.MaxSwitches})
+	}
+	// 倒计时中（state 2）距离结束不足 SwitchLockSeconds 秒时锁定
+	if dtsGame.State == 2 && cfg.SwitchLockSeconds > 0 && dtsGame.EndTime-now.Unix() <= int64(cfg.SwitchLockSeconds) {
+		return util.NewBizErr("SwitchLocked", map[string]interface{}{"Seconds": cfg.SwitchLockSeconds})
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"test/internal/model"
+)
+
+func TestCheckSwitchRules(t *testing.T) {
+	now := time.Now()
+	cfg := &model.GameConfig{RoomCount: 9, MaxSwitches: 2, SwitchLockSeconds: 5}
+	open := &model.LmDtsGame{State: 1}
+	record := &model.LmDtsRecord{RoomId: 1}
+
+	if err := CheckSwitchRules(cfg, open, record, 2, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if key := bizKey(CheckSwitchRules(cfg, open, record, 1, now)); key != "SwitchSameRoom" {
+		t.Fatalf("got %q", key)
+	}
+	if key := bizKey(CheckSwitchRules(cfg, open, record, 10, now)); key != "BetInvalidRoom" {
+		t.Fatalf("got %q", key)
+	}
+	if key := bizKey(CheckSwitchRules(cfg, open, &model.LmDtsRecord{RoomId: 1, SwitchCount: 2}, 2, now)); key != "SwitchLimitReached" {
+		t.Fatalf("got %q", key)
+	}
+
+	// 倒计时最后几秒锁定，之前可以换
+	countdown := &model.LmDtsGame{State: 2, EndTime: now.Unix() + 5}
+	if key := bizKey(CheckSwitchRules(cfg, countdown, record, 2, now)); key != "SwitchLocked" {
+		t.Fatalf("got %q", key)
+	}
+	countdown.EndTime = now.Unix() + 6
+	if err := CheckSwitchRules(cfg, countdown, record, 2, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 不设锁定时间，倒计时结束后、结算之前也不能换
+	noLock := &model.GameConfig{RoomCount: 9}
+	due := &model.LmDtsGame{State: 2, EndTime: now.Unix()}
+	if key := bizKey(CheckSwitchRules(noLock, due, record, 2, now)); key != "GameSettling" {
+		t.Fatalf("got %q", key)
+	}
+	if key := bizKey(CheckSwitchRules(noLock, &model.LmDtsGame{State: 3}, record, 2, now)); key != "GameEnded" {
+		t.Fatalf("got %q", key)
+	}
+
+	disabled := &model.GameConfig{RoomCount: 9, MaxSwitches: -1}
+	if key := bizKey(CheckSwitchRules(disabled, open, record, 2, now)); key != "SwitchDisabled" {
+		t.Fatalf("got %q", key)
+	}
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"test/internal/game"
+	"test/internal/model"
+	"test/pkg/database"
+	"test/pkg/money"
+	"test/pkg/util"
+)
+
+// 结算复算：按开局时的配置版本重新跑一遍派奖公式，和库里保存的结果逐项比对
+// 金额都是精确到分的 money.Money，比对时不需要容差
+
+// DtsAuditTotals 一局的汇总数据
+type DtsAuditTotals struct {
+	TotalPeople       int64       `json:"total_people"`
+	TotalAmount       money.Money `json:"total_amount"`
+	TotalKillerAmount money.Money `json:"total_killer_amount"`
+	TotalBonus        money.Money `json:"total_bonus"`
+	PoolDust          money.Money `json:"pool_dust"`
+	RakeRate          float64     `json:"rake_rate"`     // 复算时为开局配置版本的抽水比例
+	RakeAmount        money.Money `json:"rake_amount"`   // 杀手位总额 - 奖池
+	HouseRevenue      money.Money `json:"house_revenue"` // 抽水 + 零头
+}
+
+// DtsAuditRecord 单条下注记录的复算结果
+type DtsAuditRecord struct {
+	RecordID       uint         `json:"record_id"`
+	UserID         int64        `json:"user_id"`
+	RoomID         int64        `json:"room_id"`
+	Amount         money.Money  `json:"amount"`
+	StoredState    int8         `json:"stored_state"`
+	ExpectedState  int8         `json:"expected_state"`
+	StoredBonus    money.Money  `json:"stored_bonus"`
+	ExpectedBonus  money.Money  `json:"expected_bonus"`
+	ExpectedPayout money.Money  `json:"expected_payout"` // 应入账：本金 + 奖金
+	Paid           int8         `json:"paid"`            // 发奖任务是否已执行
+	LedgerPayout   *money.Money `json:"ledger_payout"`   // 钱包流水里实际入账的派奖，未核对流水时为空
+	Matched        bool         `json:"matched"`         // 这条记录没有任何差异
+	Diffs          []string     `json:"diffs,omitempty"` // 有差异的字段
+}
+
+// DtsAuditDiff 一处差异，RecordID 为 0 表示游戏主表上的字段
+type DtsAuditDiff struct {
+	RecordID uint   `json:"record_id,omitempty"`
+	Field    string `json:"field"`
+	Stored   string `json:"stored"`
+	Expected string `json:"expected"`
+}
+
+// DtsAuditReport 一局游戏的复算报告
+type DtsAuditReport struct {
+	GameID        uint             `json:"game_id"`
+	State         int8             `json:"state"`
+	ConfigVersion int              `json:"config_version"`
+	PayoutRate    float64          `json:"payout_rate"`
+	KillerRoom    int64            `json:"killer_room"`   // 库里保存的杀手房间，复算以它为准
+	ComputedRoom  int64            `json:"computed_room"` // 按种子重新计算的杀手房间，没有种子时为 0
+	Stored        DtsAuditTotals   `json:"stored"`
+	Expected      DtsAuditTotals   `json:"expected"`
+	Records       []DtsAuditRecord `json:"records"`
+	Diffs         []DtsAuditDiff   `json:"diffs"`
+	LedgerChecked bool             `json:"ledger_checked"` // 是否核对了钱包流水
+	Matched       bool             `json:"matched"`        // 没有任何差异
+
+	recordIDs []uint
+}
+
+// RecordIDs 本局所有下注记录的 ID
+func (r *DtsAuditReport) RecordIDs() []uint {
+	return r.recordIDs
+}
+
+func (r *DtsAuditReport) addDiff(recordID uint, field string, stored, expected interface{}) {
+	r.Diffs = append(r.Diffs, DtsAuditDiff{
+		RecordID: recordID,
+		Field:    field,
+		Stored:   fmt.Sprint(stored),
+		Expected: fmt.Sprint(expected),
+	})
+}
+
+// LegacySettlement 本局是在记录抽水字段之前结算的：rake_rate、house_revenue 都是 0
+// 这些局只能按 杀手位总额 - 总奖金 推算平台收入，和营收统计的口径一致
+func (r *DtsAuditReport) LegacySettlement() bool {
+	return r.Stored.RakeRate == 0 && r.Stored.HouseRevenue.IsZero()
+}
+
+// checkRake 核对结算时记下的抽水比例、抽水金额和平台收入是否与开局配置版本复算的结果一致
+func (r *DtsAuditReport) checkRake() {
+	if r.LegacySettlement() {
+		return
+	}
+	if r.Stored.RakeRate != r.Expected.RakeRate {
+		r.addDiff(0, "rake_rate", r.Stored.RakeRate, r.Expected.RakeRate)
+	}
+	if r.Stored.RakeAmount != r.Expected.RakeAmount {
+		r.addDiff(0, "rake_amount", r.Stored.RakeAmount, r.Expected.RakeAmount)
+	}
+	if r.Stored.HouseRevenue != r.Expected.HouseRevenue {
+		r.addDiff(0, "house_revenue", r.Stored.HouseRevenue, r.Expected.HouseRevenue)
+	}
+}
+
+// AuditDtsGame 复算一局已结算的大逃杀，checkLedger 为 true 时同时核对钱包流水里的派奖
+func AuditDtsGame(ctx context.Context, gameID uint, checkLedger bool) (*DtsAuditReport, error) {
+	var dtsGame model.LmDtsGame
+	err := database.DB.WithContext(ctx).
+		Preload("Records", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		First(&dtsGame, gameID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, util.NewBizErr("GameNotFound", nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if dtsGame.State != 3 {
+		return nil, util.NewBizErr("GameNotSettled", nil)
+	}
+
+	cfg, err := GetGameConfigVersion(ctx, game.TypeDts, dtsGame.ConfigVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	settlement := SettleDts(dtsGame.Records, dtsGame.KillerRoom, cfg.PayoutRate)
+	report := &DtsAuditReport{
+		GameID:        dtsGame.ID,
+		State:         dtsGame.State,
+		ConfigVersion: dtsGame.ConfigVersion,
+		PayoutRate:    cfg.PayoutRate,
+		KillerRoom:    dtsGame.KillerRoom,
+		Stored: DtsAuditTotals{
+			TotalPeople:       dtsGame.TotalPeople,
+			TotalAmount:       dtsGame.TotalAmount,
+			TotalKillerAmount: dtsGame.TotalKillerAmount,
+			TotalBonus:        dtsGame.TotalBonus,
+			PoolDust:          dtsGame.PoolDust,
+			RakeRate:          dtsGame.RakeRate,
+			RakeAmount:        dtsGame.RakeAmount,
+			HouseRevenue:      dtsGame.HouseRevenue,
+		},
+		Expected: DtsAuditTotals{
+			TotalPeople:       settlement.TotalPeople,
+			TotalAmount:       settlement.TotalAmount,
+			TotalKillerAmount: settlement.TotalKillerAmount,
+			TotalBonus:        settlement.TotalBonus,
+			PoolDust:          settlement.PoolDust,
+			RakeRate:          cfg.RakeRate,
+			RakeAmount:        settlement.Rake,
+			HouseRevenue:      settlement.HouseRevenue,
+		},
+		Records:       make([]DtsAuditRecord, 0, len(settlement.Records)),
+		recordIDs:     make([]uint, 0, len(settlement.Records)),
+		Diffs:         []DtsAuditDiff{},
+		LedgerChecked: checkLedger,
+	}
+
+	for _, item := range settlement.Records {
+		report.recordIDs = append(report.recordIDs, item.RecordID)
+	}
+
+	// 有种子的局顺便复算杀手房间，种子对不上说明杀手房间本身就有问题
+	if dtsGame.ServerSeed != "" {
+		report.ComputedRoom = PickKillerRoom(dtsGame.ServerSeed, dtsGame.ClientSeed, dtsGame.ID, CandidateRooms(dtsGame.Records))
+		if report.ComputedRoom != dtsGame.KillerRoom {
+			report.addDiff(0, "killer_room", dtsGame.KillerRoom, report.ComputedRoom)
+		}
+	}
+
+	if report.Stored.TotalPeople != report.Expected.TotalPeople {
+		report.addDiff(0, "total_people", report.Stored.TotalPeople, report.Expected.TotalPeople)
+	}
+	for _, total := range []struct {
+		field            string
+		stored, expected money.Money
+	}{
+		{"total_amount", report.Stored.TotalAmount, report.Expected.TotalAmount},
+		{"total_killer_amount", report.Stored.TotalKillerAmount, report.Expected.TotalKillerAmount},
+		{"total_bonus", report.Stored.TotalBonus, report.Expected.TotalBonus},
+		{"pool_dust", report.Stored.PoolDust, report.Expected.PoolDust},
+	} {
+		if total.stored != total.expected {
+			report.addDiff(0, total.field, total.stored, total.expected)
+		}
+	}
+
+	report.checkRake()
+
+	var ledger map[uint]money.Money
+	if checkLedger {
+		if ledger, err = ledgerByRecord(ctx, report.RecordIDs(), TxTypePayout); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, item := range settlement.Records {
+		stored := dtsGame.Records[i]
+		rec := DtsAuditRecord{
+			RecordID:       item.RecordID,
+			UserID:         item.UserID,
+			RoomID:         item.RoomID,
+			Amount:         item.Amount,
+			StoredState:    stored.State,
+			ExpectedState:  item.State,
+			StoredBonus:    stored.Bonus,
+			ExpectedBonus:  item.Bonus,
+			ExpectedPayout: item.Payout,
+			Paid:           stored.Paid,
+		}
+		diff := func(field string, storedValue, expectedValue interface{}) {
+			rec.Diffs = append(rec.Diffs, field)
+			report.addDiff(rec.RecordID, field, storedValue, expectedValue)
+		}
+
+		if rec.StoredState != rec.ExpectedState {
+			diff("state", rec.StoredState, rec.ExpectedState)
+		}
+		if rec.StoredBonus != rec.ExpectedBonus {
+			diff("bonus", rec.StoredBonus, rec.ExpectedBonus)
+		}
+		if checkLedger {
+			paid := ledger[rec.RecordID]
+			rec.LedgerPayout = &paid
+			if paid != rec.ExpectedPayout {
+				diff("ledger_payout", paid, rec.ExpectedPayout)
+			}
+			// 已入账但没标记已派，重放任务时会被幂等挡住；反过来则是钱没到账
+			expectedPaid := int8(0)
+			if !paid.IsZero() {
+				expectedPaid = 1
+			}
+			if rec.ExpectedState == 1 && rec.Paid != expectedPaid {
+				diff("paid", rec.Paid, expectedPaid)
+			}
+		}
+		rec.Matched = len(rec.Diffs) == 0
+		report.Records = append(report.Records, rec)
+	}
+
+	report.Matched = len(report.Diffs) == 0
+	return report, nil
+}
+
+// ledgerByRecord 每条下注记录在钱包流水里某一类型的用户侧分录合计（下注为负数，派奖为正数）
+func ledgerByRecord(ctx context.Context, ids []uint, txType string) (map[uint]money.Money, error) {
+	sums := make(map[uint]money.Money, len(ids))
+	if len(ids) == 0 {
+		return sums, nil
+	}
+
+	var rows []struct {
+		RefId  int64
+		Amount money.Money
+	}
+	// 平台账户（user_id = 0）的对手分录不算，只看用户侧入账
+	err := database.DB.WithContext(ctx).Model(&model.WalletTransaction{}).
+		Select("ref_id, SUM(amount) as amount").
+		Where("ref_type = ? AND ref_id IN ? AND type = ? AND user_id <> 0", RefTypeRecord, ids, txType).
+		Group("ref_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		sums[uint(row.RefId)] = row.Amount
+	}
+	return sums, nil
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"testing"
+
+	"test/pkg/money"
+)
+
+func TestAuditCheckRake(t *testing.T) {
+	expected := DtsAuditTotals{RakeRate: 0.1, RakeAmount: money.FromYuan(10), HouseRevenue: money.FromCents(1002)}
+
+	report := &DtsAuditReport{Stored: expected, Expected: expected}
+	report.checkRake()
+	if len(report.Diffs) != 0 {
+		t.Fatalf("diffs = %+v, want none", report.Diffs)
+	}
+
+	// 结算时用错了抽水比例，抽水和平台收入跟着错
+	stored := DtsAuditTotals{RakeRate: 0.05, RakeAmount: money.FromYuan(5), HouseRevenue: money.FromCents(502)}
+	report = &DtsAuditReport{Stored: stored, Expected: expected}
+	report.checkRake()
+	fields := make(map[string]bool)
+	for _, diff := range report.Diffs {
+		fields[diff.Field] = true
+	}
+	for _, field := range []string{"rake_rate", "rake_amount", "house_revenue"} {
+		if !fields[field] {
+			t.Fatalf("missing %s diff: %+v", field, report.Diffs)
+		}
+	}
+
+	// 记录抽水字段之前结算的局不比对
+	report = &DtsAuditReport{Expected: expected}
+	report.checkRake()
+	if !report.LegacySettlement() || len(report.Diffs) != 0 {
+		t.Fatalf("legacy diffs = %+v, want none", report.Diffs)
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+
+	"github.com/shopspring/decimal"
+	"test/internal/dao"
+	"test/internal/model"
+	"test/pkg/database"
+	"test/pkg/money"
+	"test/pkg/util"
+)
+
+// ListSettledGames 历史开奖
+func ListSettledGames(ctx context.Context, req util.PaginationReq) ([]model.LmDtsGame, int64, error) {
+	dtsDao := dao.NewDtsDao(database.DB)
+	games, total, err := dtsDao.ListSettledGames(ctx, req.GetOffset(), req.GetSize())
+	if err != nil {
+		return nil, 0, util.NewBizErr("SystemBusy", nil)
+	}
+	return games, total, nil
+}
+
+// ListUserRecords 用户自己的下注记录
+func ListUserRecords(ctx context.Context, userID int64, req util.PaginationReq) ([]model.LmDtsRecord, int64, error) {
+	dtsDao := dao.NewDtsDao(database.DB)
+	records, total, err := dtsDao.ListUserRecords(ctx, userID, req.GetOffset(), req.GetSize())
+	if err != nil {
+		return nil, 0, util.NewBizErr("SystemBusy", nil)
+	}
+	return records, total, nil
+}
+
+// DtsUserStats 用户战绩
+type DtsUserStats struct {
+	Rounds     int64           `json:"rounds"`      // 参与局数（含未结算）
+	Wins       int64           `json:"wins"`        // 胜局
+	Losses     int64           `json:"losses"`      // 负局
+	WinRate    decimal.Decimal `json:"win_rate"`    // 胜率 = 胜局 / 已结算局，保留 4 位小数
+	TotalBet   money.Money     `json:"total_bet"`   // 累计下注
+	TotalBonus money.Money     `json:"total_bonus"` // 累计奖金
+	NetProfit  money.Money     `json:"net_profit"`  // 净盈亏 = 奖金 - 被杀输掉的本金
+}
+
+// GetUserStats 用户战绩汇总
+func GetUserStats(ctx context.Context, userID int64) (*DtsUserStats, error) {
+	dtsDao := dao.NewDtsDao(database.DB)
+	raw, err := dtsDao.GetUserRecordStats(ctx, userID)
+	if err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+
+	stats := &DtsUserStats{
+		Rounds:     raw.Rounds,
+		Wins:       raw.Wins,
+		Losses:     raw.Losses,
+		WinRate:    decimal.Zero,
+		TotalBet:   raw.TotalBet,
+		TotalBonus: raw.TotalBonus,
+	}
+	if settled := raw.Wins + raw.Losses; settled > 0 {
+		stats.WinRate = decimal.NewFromInt(raw.Wins).DivRound(decimal.NewFromInt(settled), 4)
+	}
+	stats.NetProfit = raw.TotalBonus - raw.TotalLost
+	return stats, nil
+}
@@ -6,22 +6,22 @@ import (
 	"errors"
 	"fmt"
 	"github.com/redis/go-redis/v9"
-	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 	"strconv"
 	"test/internal/model"
 	"test/pkg/database"
+	"test/pkg/money"
 	myredis "test/pkg/redis"
 	"time"
 )
 
 type DtsUserCache struct {
-	UserID   int64   `json:"user_id"`
-	Nickname string  `json:"nickname"`
-	GameID   uint    `json:"game_id"`
-	RoomID   int     `json:"room_id"`
-	Amount   float64 `json:"amount"`
-	Bonus    float64 `json:"bonus"`
+	UserID   int64       `json:"user_id"`
+	Nickname string      `json:"nickname"`
+	GameID   uint        `json:"game_id"`
+	RoomID   int         `json:"room_id"`
+	Amount   money.Money `json:"amount"`
+	Bonus    money.Money `json:"bonus"`
 }
 
 // 1. 定义一个专门的请求结构体
@@ -29,7 +29,7 @@ type JoinGameReq struct {
 	GameID   uint
 	UserID   int64
 	RoomID   int
-	Amount   float64
+	Amount   money.Money
 	Nickname string
 }
 
@@ -82,7 +82,7 @@ func RemoveUserList(ctx context.Context, GameID, UserID int64) error {
 
 func DeleteUserList(ctx context.Context, GameID int64) error {
 	key := fmt.Sprintf("%s:%d", Key, GameID)
-	_, err := myredis.RedisClient.HDel(ctx, key).Result()
+	_, err := myredis.RedisClient.Del(ctx, key).Result()
 	return err
 }
 
@@ -129,50 +129,52 @@ func GetUserList(ctx context.Context, GameID int64) ([]DtsUserCache, error) {
 	return userList, nil
 }
 
-func HasLock(ctx context.Context) bool {
-	exists, _ := myredis.RedisClient.Exists(ctx, "game_dts_lock_flag").Result()
-	if exists == 0 {
-		return false
-	}
-	return true
-}
-
-const (
-	MaxPeople = 2
-	Duration  = 30
-)
-
-func UpdateGame(tx *gorm.DB, game *model.LmDtsGame) error {
+// UpdateGame 人数达标时开始倒计时，返回本次调用是否触发了倒计时
+func UpdateGame(tx *gorm.DB, game *model.LmDtsGame) (bool, error) {
 	// 1. 状态校验：只有进行中(1)的场次才能触发倒计时
 	if game.State != 1 {
-		return nil
+		return false, nil
+	}
+
+	// 人数阈值、倒计时时长以开局时的配置版本为准
+	cfg, err := GetDtsGameConfig(tx.Statement.Context, game)
+	if err != nil {
+		return false, err
 	}
 
 	// 2. 统计参与人数 (对应 $game->records()->count())
 	var total int64
 	// 使用关联统计，不需要把记录全查出来
-	err := tx.Model(&model.LmDtsRecord{}).Where("game_id = ?", game.ID).Count(&total).Error
+	err = tx.Model(&model.LmDtsRecord{}).Where("game_id = ?", game.ID).Count(&total).Error
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// 3. 检查是否达到人数阈值
-	if total >= int64(MaxPeople) {
-		now := time.Now().Unix()
-
-		// 4. 更新状态为倒计时中(2)
-		// 使用 Map 更新以确保所有字段（包括可能为0的字段）都能被正确写入
-		err := tx.Model(game).Updates(map[string]interface{}{
-			"state":      2,
-			"start_time": now,
-			"end_time":   now + int64(Duration),
-		}).Error
-
-		if err != nil {
-			return err
+	if total >= int64(cfg.MaxPeople) {
+		if err := startCountdown(tx, game, cfg); err != nil {
+			return false, err
 		}
+		return true, nil
 	}
 
+	return false, nil
+}
+
+// startCountdown 把一局改成倒计时中(2)，人数达标和等待超时强制开始都走这里
+func startCountdown(tx *gorm.DB, game *model.LmDtsGame, cfg *model.GameConfig) error {
+	now := time.Now().Unix()
+
+	// 使用 Map 更新以确保所有字段（包括可能为0的字段）都能被正确写入
+	err := tx.Model(game).Updates(map[string]interface{}{
+		"state":      2,
+		"start_time": now,
+		"end_time":   now + int64(cfg.Duration),
+	}).Error
+	if err != nil {
+		return err
+	}
+	game.State, game.StartTime, game.EndTime = 2, now, now+int64(cfg.Duration)
 	return nil
 }
 
@@ -201,41 +203,31 @@ func GetGame(gameID uint) (*model.LmDtsGame, error) {
 }
 
 type RoomAmount struct {
-	RoomID int             `json:"room_id"`
-	Amount decimal.Decimal `json:"amount"` // 使用 decimal 类型保证精度
+	RoomID int         `json:"room_id"`
+	Amount money.Money `json:"amount"`
 }
 
-func CalcRoomAmount(userList []DtsUserCache) []RoomAmount {
+func CalcRoomAmount(userList []DtsUserCache, roomCount int) []RoomAmount {
 
 	// 1. 初始化一个 Map 用于存放每个房间的金额累加
 	// key 是房间 ID，value 是累加的金额
-	roomMap := make(map[int]decimal.Decimal)
+	roomMap := make(map[int]money.Money)
 
 	// 2. 只需要遍历一次用户列表 (O(N) 时间复杂度)
 	for _, item := range userList {
 		if item.RoomID > 0 {
-			// 获取当前房间已有的金额（如果不存在则是 0 值）
-			currentTotal := roomMap[int(item.RoomID)]
-			// ❌ 错误写法：currentTotal.Add(item.Amount)
-			// ✅ 正确写法：使用 NewFromFloat 将 float64 转为 Decimal
-			changeAmount := decimal.NewFromFloat(item.Amount)
-
-			// 执行加法并存回 Map
-			roomMap[int(item.RoomID)] = currentTotal.Add(changeAmount)
+			// 金额以分为单位的整数，直接累加（不存在的房间是 0 值）
+			roomMap[int(item.RoomID)] += item.Amount
 		}
 	}
 
-	// 3. 构建返回数据 (固定 1-9 号房间)
-	results := make([]RoomAmount, 0, 9)
-	for i := 1; i <= 9; i++ {
-		amount, exists := roomMap[i]
-		if !exists {
-			amount = decimal.NewFromInt(0) // 如果该房间没人，金额为 0
-		}
-
+	// 3. 构建返回数据 (1 - roomCount 号房间，房间数来自玩法配置)
+	results := make([]RoomAmount, 0, roomCount)
+	for i := 1; i <= roomCount; i++ {
+		// 如果该房间没人，金额为 0
 		results = append(results, RoomAmount{
 			RoomID: i,
-			Amount: amount,
+			Amount: roomMap[i],
 		})
 	}
 
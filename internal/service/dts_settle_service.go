@@ -0,0 +1,95 @@
+package service
+
+import (
+	"github.com/shopspring/decimal"
+	"test/internal/model"
+	"test/pkg/money"
+)
+
+// DtsSettlement 按派奖公式算出的一局结果，结算和离线复算共用，保证两边的算法完全一致
+type DtsSettlement struct {
+	KillerRoom        int64
+	TotalPeople       int64
+	TotalAmount       money.Money // 所有房间的投注
+	TotalKillerAmount money.Money // 被刀房间的投注
+	Pool              money.Money // 奖池：被刀房间的投注 × 派奖比例，向下取整到分
+	TotalBonus        money.Money // 本局总派发奖金
+	PoolDust          money.Money // 奖池按比例拆分后剩下的零头（Pool - TotalBonus），留在平台账户
+	NoWinner          bool        // 所有下注都在被刀房间，没有胜者
+	Rake              money.Money // 平台抽水：被刀房间的投注 - 奖池；没有胜者时为被刀房间的全部投注
+	HouseRevenue      money.Money // 平台本局收入：抽水 + 零头，等于 Σ下注 - Σ派奖
+	Records           []DtsRecordSettlement
+}
+
+// DtsRecordSettlement 单条下注记录的结算结果
+type DtsRecordSettlement struct {
+	RecordID uint
+	UserID   int64
+	RoomID   int64
+	Amount   money.Money
+	State    int8        // 1:胜 2:负
+	Bonus    money.Money // 奖金，不含本金
+	Payout   money.Money // 发奖任务应入账的金额：胜者为本金 + 奖金，败者为 0
+}
+
+// SettleDts 按杀手房间和派奖比例计算每条记录的输赢和奖金
+// 取整规则：奖池 = floor(killerAmount × payoutRate)，每位胜者 bonus = floor(奖池 × personalAmt / 胜出者总投注额)，都精确到分
+// 每人向下取整后剩下的零头（不超过胜者人数 - 1 分）记为 PoolDust，不再分配，和未进入奖池的部分一样留在平台账户，
+// 所以 Σ下注 = Σ派奖 + 平台留存，一分钱都不会凭空出现或消失
+func SettleDts(records []model.LmDtsRecord, killerRoom int64, payoutRate float64) *DtsSettlement {
+	result := &DtsSettlement{
+		KillerRoom: killerRoom,
+		Records:    make([]DtsRecordSettlement, 0, len(records)),
+	}
+	for _, record := range records {
+		result.TotalAmount += record.Amount
+		if record.RoomId == killerRoom {
+			result.TotalKillerAmount += record.Amount
+		}
+	}
+
+	divisor := result.TotalAmount - result.TotalKillerAmount // 胜出者总投注额
+	result.NoWinner = len(records) > 0 && !divisor.IsPositive()
+	if !result.NoWinner {
+		// 没有胜者时不设奖池，被刀房间的投注全部留给平台
+		result.Pool = money.FloorDecimal(result.TotalKillerAmount.Decimal().Mul(decimal.NewFromFloat(payoutRate)))
+	}
+	// 奖池向下取整舍掉的不足一分也算进抽水
+	result.Rake = result.TotalKillerAmount - result.Pool
+
+	for _, record := range records {
+		result.TotalPeople++
+		item := DtsRecordSettlement{
+			RecordID: record.ID,
+			UserID:   record.UserId,
+			RoomID:   record.RoomId,
+			Amount:   record.Amount,
+		}
+
+		if record.RoomId == killerRoom {
+			// 判定为失败（被杀）
+			item.State = 2
+			result.Records = append(result.Records, item)
+			continue
+		}
+
+		item.State = 1
+		item.Bonus = splitPool(result.Pool, record.Amount, divisor)
+		item.Payout = item.Bonus + record.Amount
+		//累计共产生多少奖金
+		result.TotalBonus += item.Bonus
+		result.Records = append(result.Records, item)
+	}
+	result.PoolDust = result.Pool - result.TotalBonus
+	result.HouseRevenue = result.Rake + result.PoolDust
+	return result
+}
+
+// splitPool floor(pool × share / total)，按分做整数运算，避免除法产生的精度问题
+func splitPool(pool, share, total money.Money) money.Money {
+	if !total.IsPositive() || !pool.IsPositive() {
+		return money.Zero
+	}
+	q, _ := decimal.NewFromInt(pool.Cents()).Mul(decimal.NewFromInt(share.Cents())).QuoRem(decimal.NewFromInt(total.Cents()), 0)
+	return money.FromCents(q.IntPart())
+}
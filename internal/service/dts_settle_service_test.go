@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+	"test/internal/model"
+	"test/pkg/money"
+)
+
+func TestSettleDts(t *testing.T) {
+	records := []model.LmDtsRecord{
+		{Model: gorm.Model{ID: 1}, UserId: 10, RoomId: 3, Amount: money.FromYuan(100)},
+		{Model: gorm.Model{ID: 2}, UserId: 11, RoomId: 5, Amount: money.FromYuan(30)},
+		{Model: gorm.Model{ID: 3}, UserId: 12, RoomId: 7, Amount: money.FromYuan(10)},
+	}
+
+	s := SettleDts(records, 3, 0.9)
+	if s.TotalPeople != 3 || s.TotalAmount != money.FromYuan(140) || s.TotalKillerAmount != money.FromYuan(100) {
+		t.Fatalf("unexpected totals: %+v", s)
+	}
+	// 被刀房间 100 * 0.9 = 90，按 30:10 分给两位胜者
+	if s.Records[0].State != 2 || !s.Records[0].Bonus.IsZero() || !s.Records[0].Payout.IsZero() {
+		t.Fatalf("loser settled wrong: %+v", s.Records[0])
+	}
+	if s.Records[1].State != 1 || s.Records[1].Bonus != money.FromCents(6750) || s.Records[1].Payout != money.FromCents(9750) {
+		t.Fatalf("winner settled wrong: %+v", s.Records[1])
+	}
+	if s.Records[2].Bonus != money.FromCents(2250) || s.TotalBonus != money.FromYuan(90) || !s.PoolDust.IsZero() {
+		t.Fatalf("unexpected bonus: %+v", s)
+	}
+	if s.NoWinner || s.Rake != money.FromYuan(10) || s.HouseRevenue != money.FromYuan(10) {
+		t.Fatalf("unexpected rake: %+v", s)
+	}
+
+	// 所有人都在被刀房间：没有胜者，不能除以 0
+	all := SettleDts(records[:1], 3, 0.9)
+	if all.Records[0].State != 2 || !all.TotalBonus.IsZero() || !all.Pool.IsZero() {
+		t.Fatalf("unexpected settlement: %+v", all)
+	}
+	// 没有胜者时被刀房间的投注全部归平台
+	if !all.NoWinner || all.HouseRevenue != money.FromYuan(100) {
+		t.Fatalf("unexpected house revenue: %+v", all)
+	}
+
+	// 没有人被刀：胜者只拿回本金
+	none := SettleDts(records, 9, 0.9)
+	if !none.TotalBonus.IsZero() || none.Records[0].Payout != money.FromYuan(100) || none.NoWinner || !none.HouseRevenue.IsZero() {
+		t.Fatalf("unexpected settlement: %+v", none)
+	}
+}
+
+func TestSettleDtsDust(t *testing.T) {
+	// 奖池 1.00 × 0.9 = 0.90 分给 7 位胜者，每人 floor(12.857) = 0.12，剩 0.06 归平台
+	records := []model.LmDtsRecord{{Model: gorm.Model{ID: 1}, RoomId: 1, Amount: money.FromYuan(1)}}
+	for i := 0; i < 7; i++ {
+		records = append(records, model.LmDtsRecord{Model: gorm.Model{ID: uint(i + 2)}, RoomId: 2, Amount: money.FromYuan(1)})
+	}
+
+	s := SettleDts(records, 1, 0.9)
+	if s.Pool != money.FromCents(90) || s.Records[1].Bonus != money.FromCents(12) || s.PoolDust != money.FromCents(6) {
+		t.Fatalf("unexpected split: %+v", s)
+	}
+
+	// 下注 = 派奖 + 平台留存，一分不差
+	var paid money.Money
+	for _, item := range s.Records {
+		paid += item.Payout
+	}
+	if house := s.TotalAmount - paid; house != s.HouseRevenue || s.HouseRevenue != s.Rake+s.PoolDust {
+		t.Fatalf("house take %v, want %v", house, s.HouseRevenue)
+	}
+}
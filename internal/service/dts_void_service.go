@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"test/internal/event"
+	"test/internal/game"
+	"test/internal/model"
+	"test/pkg/database"
+	"test/pkg/money"
+	"test/pkg/util"
+)
+
+// DtsVoidResult 作废一局的结果
+type DtsVoidResult struct {
+	GameID        uint        `json:"game_id"`
+	PrevState     int8        `json:"prev_state"`     // 作废前的游戏状态
+	AlreadyVoided bool        `json:"already_voided"` // 之前已经作废过，本次只补退遗漏的记录
+	Records       int         `json:"records"`        // 本次退款的下注记录数
+	Refunded      money.Money `json:"refunded"`       // 退回的下注本金和换房手续费
+	Reversed      money.Money `json:"reversed"`       // 撤回的已派奖金
+}
+
+// VoidDtsGame 后台作废一局：退回每条下注记录的本金和换房手续费，撤回已经派发的奖金，清空 Redis 用户列表
+// 每条记录的退款和把记录改成作废（state 3）在同一个事务里，重复调用只会处理还没退过的记录，不会重复退款
+// 作废的是当前还在下注的局时，顺带开下一局
+func VoidDtsGame(ctx context.Context, gameID uint, reason string, operator int64) (*DtsVoidResult, error) {
+	return voidDtsGame(ctx, gameID, reason, operator, nil)
+}
+
+// voidDtsGame check 不为空时在锁住游戏行之后调用，返回 error 则放弃作废（例如等待超时退款前局面已经变了）
+// 不看结算锁：游戏行的 FOR UPDATE 才是和结算、下注串行的地方，结算在同一把行锁内重新读取下注记录
+func voidDtsGame(ctx context.Context, gameID uint, reason string, operator int64, check func(*model.LmDtsGame) error) (*DtsVoidResult, error) {
+	result := &DtsVoidResult{GameID: gameID}
+	var dtsGame model.LmDtsGame
+	// 每个玩家的退款明细，提交后随作废事件推送给本人
+	refunds := make(map[int64]map[string]interface{})
+	// 已结算的局要把计入排行榜的盈亏冲回去
+	profits := make(map[int64]money.Money)
+	// check 返回的错误原样交给调用方
+	var checkErr error
+
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 锁住游戏行：和结算事务互斥，结算提交后这里读到的就是 state 3
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&dtsGame, gameID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return util.NewBizErr("GameNotFound", nil)
+		}
+		if err != nil {
+			return err
+		}
+		if check != nil {
+			if checkErr = check(&dtsGame); checkErr != nil {
+				return checkErr
+			}
+		}
+		result.PrevState = dtsGame.State
+		result.AlreadyVoided = dtsGame.State == 4
+
+		var records []model.LmDtsRecord
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("game_id = ? AND state <> ?", gameID, 3).
+			Order("id asc").Find(&records).Error; err != nil {
+			return err
+		}
+
+		ids := make([]uint, 0, len(records))
+		for _, record := range records {
+			ids = append(ids, record.ID)
+		}
+		fees, payouts, err := voidLedger(tx, ids)
+		if err != nil {
+			return err
+		}
+
+		remark := fmt.Sprintf("对局 %d 作废：%s", gameID, reason)
+		for _, record := range records {
+			// 换房手续费在流水里是负数
+			refund := record.Amount - fees[record.ID]
+			if refund.IsPositive() {
+				if _, err := ChangeBalance(tx, WalletChange{
+					UserID:  record.UserId,
+					Type:    TxTypeRefund,
+					Amount:  refund,
+					RefType: RefTypeRecord,
+					RefID:   int64(record.ID),
+					Remark:  remark,
+				}); err != nil {
+					return err
+				}
+			}
+
+			// 以流水为准撤回奖金，不依赖 paid 标记；玩家已经花掉的奖金允许扣成负数，由运营后续处理
+			reversed := payouts[record.ID]
+			if reversed.IsPositive() {
+				if _, err := ChangeBalance(tx, WalletChange{
+					UserID:        record.UserId,
+					Type:          TxTypeReversal,
+					Amount:        reversed.Neg(),
+					RefType:       RefTypeRecord,
+					RefID:         int64(record.ID),
+					Remark:        remark,
+					AllowNegative: true,
+				}); err != nil {
+					return err
+				}
+			}
+
+			// 记录改成作废后发奖任务（state = 1 才派）会直接跳过，队列里还没派的奖金不会再发出去
+			if err := tx.Model(&record).UpdateColumn("state", 3).Error; err != nil {
+				return err
+			}
+
+			if dtsGame.State == 3 {
+				switch record.State {
+				case 1:
+					profits[record.UserId] = record.Bonus.Neg()
+				case 2:
+					profits[record.UserId] = record.Amount
+				}
+			}
+			refunds[record.UserId] = map[string]interface{}{
+				"record_id": record.ID,
+				"refund":    refund,
+				"reversed":  reversed,
+			}
+			result.Records++
+			result.Refunded += refund
+			result.Reversed += reversed
+		}
+
+		if result.AlreadyVoided {
+			return nil
+		}
+
+		now := time.Now().Unix()
+		if err := tx.Model(&dtsGame).Updates(map[string]interface{}{
+			"state":         4, // 4:已作废
+			"void_reason":   reason,
+			"void_operator": operator,
+			"voided_at":     now,
+		}).Error; err != nil {
+			return err
+		}
+
+		// 作废的局不再参与对账，之前发现的差异一并关闭
+		return tx.Model(&model.ReconcileDiscrepancy{}).
+			Where("game_id = ? AND status = ?", gameID, DiscrepancyOpen).
+			Updates(map[string]interface{}{
+				"status":      DiscrepancyIgnored,
+				"remark":      remark,
+				"operator":    operator,
+				"resolved_at": now,
+			}).Error
+	})
+	if err != nil {
+		var bizErr *util.BizError
+		if checkErr != nil || errors.As(err, &bizErr) {
+			return nil, err
+		}
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+
+	// 以下都是提交之后的清理，失败不影响已经完成的退款
+	if err := DeleteUserList(ctx, int64(gameID)); err != nil {
+		fmt.Printf("作废后清理用户列表失败 game_id=%d: %v\n", gameID, err)
+	}
+	if len(profits) > 0 {
+		// 排行榜按结算时间分桶，calc 把结算时间写在 end_time 里
+		if err := RecordRoundProfits(ctx, time.Unix(dtsGame.EndTime, 0), profits); err != nil {
+			fmt.Printf("作废后冲回排行榜失败 game_id=%d: %v\n", gameID, err)
+		}
+	}
+	if result.PrevState == 3 {
+		// 已结算的局不再计入营收，重算结算当天的汇总
+		if _, err := RefreshDtsDailyRevenue(ctx, time.Unix(dtsGame.EndTime, 0)); err != nil {
+			fmt.Printf("作废后更新营收汇总失败 game_id=%d: %v\n", gameID, err)
+		}
+	}
+
+	if !result.AlreadyVoided || result.Records > 0 {
+		event.Publish(event.Event{
+			Type:     event.GameVoided,
+			GameType: game.TypeDts,
+			GameID:   gameID,
+			Data:     map[string]interface{}{"reason": reason, "refunds": refunds},
+		})
+	}
+
+	// 作废的是正在下注的局：ticker 只在结算后开新局，这里补开一局
+	if !result.AlreadyVoided && result.PrevState < 3 {
+		if lastID, _ := GetLastGameId(ctx); lastID == gameID {
+			if engine, ok := game.Get(game.TypeDts); ok {
+				if err := engine.CreateRound(ctx, dtsGame.PreKillerRoom); err != nil {
+					fmt.Printf("作废后开局失败 game_id=%d: %v\n", gameID, err)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// voidLedger 在事务内按下注记录汇总换房手续费和已派奖金（只看用户侧分录）
+func voidLedger(tx *gorm.DB, ids []uint) (fees, payouts map[uint]money.Money, err error) {
+	fees = make(map[uint]money.Money, len(ids))
+	payouts = make(map[uint]money.Money, len(ids))
+	if len(ids) == 0 {
+		return fees, payouts, nil
+	}
+
+	var rows []struct {
+		RefId  int64
+		Type   string
+		Amount money.Money
+	}
+	err = tx.Model(&model.WalletTransaction{}).
+		Select("ref_id, type, SUM(amount) as amount").
+		Where("ref_type = ? AND ref_id IN ? AND type IN ? AND user_id <> ?", RefTypeRecord, ids, []string{TxTypeSwitchFee, TxTypePayout}, HouseAccountID).
+		Group("ref_id, type").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, row := range rows {
+		switch row.Type {
+		case TxTypeSwitchFee:
+			fees[uint(row.RefId)] = row.Amount
+		case TxTypePayout:
+			payouts[uint(row.RefId)] = row.Amount
+		}
+	}
+	return fees, payouts, nil
+}
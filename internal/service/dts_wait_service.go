@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"test/internal/event"
+	"test/internal/game"
+	"test/internal/model"
+	"test/pkg/database"
+)
+
+// 等待超时后的处理方式（GameConfig.WaitTimeoutPolicy）
+const (
+	WaitPolicyRefund = "refund" // 退回全部下注并开下一局
+	WaitPolicyStart  = "start"  // 不再等人，直接开始倒计时
+)
+
+// waitTimeoutReason 等待超时自动退款时写进作废原因和推送里的说明
+const waitTimeoutReason = "等待超时，人数不足自动退款"
+
+// errRoundNotWaiting 锁住游戏行后发现这一局已经不在等人了（刚好有人加入开始了倒计时，或已被作废）
+var errRoundNotWaiting = errors.New("round is no longer waiting for players")
+
+// WaitTimedOut 从第一笔下注起是否已经等够了 MaxWaitSeconds，0 表示一直等
+func WaitTimedOut(cfg *model.GameConfig, firstBetAt, now time.Time) bool {
+	if cfg.MaxWaitSeconds <= 0 {
+		return false
+	}
+	return !now.Before(firstBetAt.Add(time.Duration(cfg.MaxWaitSeconds) * time.Second))
+}
+
+// ExpireDtsRounds 处理人数迟迟不够、一直停在 state 1 的局，由结算 ticker 在结算锁内调用
+// 没人下注的局不算等待，不会被处理
+func ExpireDtsRounds(ctx context.Context) error {
+	var games []model.LmDtsGame
+	if err := database.DB.WithContext(ctx).Where("state = ?", 1).Order("id asc").Find(&games).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range games {
+		dtsGame := &games[i]
+		cfg, err := GetDtsGameConfig(ctx, dtsGame)
+		if err != nil {
+			return err
+		}
+		if cfg.MaxWaitSeconds <= 0 {
+			continue
+		}
+
+		var firstBet model.LmDtsRecord
+		result := database.DB.WithContext(ctx).Where("game_id = ?", dtsGame.ID).Order("id asc").Limit(1).Find(&firstBet)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 || !WaitTimedOut(cfg, firstBet.CreatedAt, now) {
+			continue
+		}
+
+		switch cfg.WaitTimeoutPolicy {
+		case WaitPolicyStart:
+			err = forceDtsCountdown(ctx, dtsGame.ID)
+		default:
+			_, err = voidDtsGame(ctx, dtsGame.ID, waitTimeoutReason, 0, func(g *model.LmDtsGame) error {
+				if g.State != 1 {
+					return errRoundNotWaiting
+				}
+				return nil
+			})
+		}
+		if errors.Is(err, errRoundNotWaiting) {
+			continue
+		}
+		if err != nil {
+			fmt.Printf("等待超时处理失败 game_id=%d policy=%s: %v\n", dtsGame.ID, cfg.WaitTimeoutPolicy, err)
+			continue
+		}
+		fmt.Printf("等待超时 game_id=%d policy=%s first_bet_at=%d\n", dtsGame.ID, cfg.WaitTimeoutPolicy, firstBet.CreatedAt.Unix())
+	}
+	return nil
+}
+
+// forceDtsCountdown 人数不够也直接开始倒计时，之后照常由 ticker 结算
+func forceDtsCountdown(ctx context.Context, gameID uint) error {
+	var dtsGame model.LmDtsGame
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&dtsGame, gameID).Error; err != nil {
+			return err
+		}
+		if dtsGame.State != 1 {
+			return errRoundNotWaiting
+		}
+		cfg, err := GetDtsGameConfig(ctx, &dtsGame)
+		if err != nil {
+			return err
+		}
+		return startCountdown(tx, &dtsGame, cfg)
+	})
+	if err != nil {
+		return err
+	}
+
+	event.Publish(event.Event{
+		Type:     event.CountdownStarted,
+		GameType: game.TypeDts,
+		GameID:   dtsGame.ID,
+		Data:     map[string]interface{}{"start_time": dtsGame.StartTime, "end_time": dtsGame.EndTime, "forced": true},
+	})
+	return nil
+}
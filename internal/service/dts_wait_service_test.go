@@ -0,0 +1,24 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"test/internal/model"
+)
+
+func TestWaitTimedOut(t *testing.T) {
+	firstBet := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	cfg := &model.GameConfig{MaxWaitSeconds: 60}
+
+	if WaitTimedOut(cfg, firstBet, firstBet.Add(59*time.Second)) {
+		t.Fatal("59s should still be waiting")
+	}
+	if !WaitTimedOut(cfg, firstBet, firstBet.Add(60*time.Second)) {
+		t.Fatal("60s should time out")
+	}
+	// 0 表示一直等
+	if WaitTimedOut(&model.GameConfig{}, firstBet, firstBet.Add(24*time.Hour)) {
+		t.Fatal("MaxWaitSeconds = 0 should never time out")
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+
+	"gorm.io/gorm"
+	"test/internal/model"
+	"test/pkg/database"
+	"test/pkg/fair"
+	"test/pkg/util"
+)
+
+// FairResult 可证明公平的校验结果
+type FairResult struct {
+	GameID         uint    `json:"game_id"`
+	ServerSeed     string  `json:"server_seed"`      // 结算后公开的服务端种子
+	ServerSeedHash string  `json:"server_seed_hash"` // 开局时公布的承诺哈希
+	ClientSeed     string  `json:"client_seed"`      // 由玩家种子合成的公共种子
+	Rooms          []int64 `json:"rooms"`            // 候选房间（本局有下注的房间，升序）
+	KillerRoom     int64   `json:"killer_room"`      // 实际开出的杀手房间
+	ComputedRoom   int64   `json:"computed_room"`    // 按公开数据重新计算的杀手房间
+	HashMatched    bool    `json:"hash_matched"`     // HashSeed(ServerSeed) == ServerSeedHash
+	Verified       bool    `json:"verified"`         // 哈希一致且计算结果与实际一致
+}
+
+// CandidateRooms 本局有下注的房间，去重并升序排列
+// 排序是为了让同样的种子在任何地方都能选出同一个下标
+func CandidateRooms(records []model.LmDtsRecord) []int64 {
+	seen := make(map[int64]bool)
+	rooms := make([]int64, 0, 9)
+	for _, record := range records {
+		if record.RoomId <= 0 || seen[record.RoomId] {
+			continue
+		}
+		seen[record.RoomId] = true
+		rooms = append(rooms, record.RoomId)
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i] < rooms[j] })
+	return rooms
+}
+
+// CombineClientSeed 按记录 ID 升序把每个玩家的 "记录ID:种子" 合成为公共种子
+// 玩家没有提交种子时仍然带上记录 ID，保证每一个下注都参与计算
+func CombineClientSeed(records []model.LmDtsRecord) string {
+	sorted := make([]model.LmDtsRecord, len(records))
+	copy(sorted, records)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	seeds := make([]string, 0, len(sorted))
+	for _, record := range sorted {
+		seeds = append(seeds, strconv.FormatUint(uint64(record.ID), 10)+":"+record.ClientSeed)
+	}
+	return fair.CombineSeeds(seeds)
+}
+
+// PickKillerRoom 根据种子从候选房间中选出杀手房间，没有候选房间时返回 0
+func PickKillerRoom(serverSeed, clientSeed string, gameID uint, rooms []int64) int64 {
+	if len(rooms) == 0 {
+		return 0
+	}
+	return rooms[fair.Roll(serverSeed, clientSeed, gameID, len(rooms))]
+}
+
+// VerifyGame 重新计算一局已结算游戏的杀手房间
+func VerifyGame(ctx context.Context, gameID uint) (*FairResult, error) {
+	var game model.LmDtsGame
+	err := database.DB.WithContext(ctx).Preload("Records").First(&game, gameID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, util.NewBizErr("GameNotFound", nil)
+	}
+	if err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+
+	// 未结算的局不能公开服务端种子，否则玩家可以提前算出结果
+	if game.State != 3 {
+		return nil, util.NewBizErr("GameNotSettled", nil)
+	}
+	if game.ServerSeed == "" || game.ServerSeedHash == "" {
+		return nil, util.NewBizErr("GameNotVerifiable", nil)
+	}
+
+	rooms := CandidateRooms(game.Records)
+	clientSeed := CombineClientSeed(game.Records)
+	computed := PickKillerRoom(game.ServerSeed, clientSeed, game.ID, rooms)
+	hashMatched := fair.HashSeed(game.ServerSeed) == game.ServerSeedHash
+
+	return &FairResult{
+		GameID:         game.ID,
+		ServerSeed:     game.ServerSeed,
+		ServerSeedHash: game.ServerSeedHash,
+		ClientSeed:     clientSeed,
+		Rooms:          rooms,
+		KillerRoom:     game.KillerRoom,
+		ComputedRoom:   computed,
+		HashMatched:    hashMatched,
+		Verified:       hashMatched && clientSeed == game.ClientSeed && computed == game.KillerRoom,
+	}, nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"test/internal/game"
+	"test/internal/model"
+	"test/pkg/database"
+	myredis "test/pkg/redis"
+	"test/pkg/util"
+)
+
+// DefaultGameConfig 没有任何配置版本时使用的默认规则（即原来写死在代码里的值）
+func DefaultGameConfig(gameType int) model.GameConfig {
+	return model.GameConfig{
+		GameType:   gameType,
+		Version:    0,
+		MaxPeople:  2,
+		Duration:   30,
+		RoomCount:  9,
+		PayoutRate: 0.9,
+		RakeRate:   0.1,
+		// 默认一直等人，和原来的行为一致
+		WaitTimeoutPolicy: WaitPolicyRefund,
+		Remark:            "default",
+	}
+}
+
+// 历史版本不会再被修改，读过一次就放进进程内缓存
+var configVersions sync.Map // key: "gameType:version" -> model.GameConfig
+
+// normalizeRakeRate 抽水比例是后加的字段，之前发布的版本 rake_rate 为 0，按派奖比例换算
+// 历史版本是不可变的，只在读出来时换算，不回写数据库
+func normalizeRakeRate(cfg *model.GameConfig) {
+	if cfg.RakeRate == 0 && cfg.PayoutRate < 1 {
+		cfg.RakeRate = decimal.NewFromInt(1).Sub(decimal.NewFromFloat(cfg.PayoutRate).Round(4)).InexactFloat64()
+	}
+}
+
+func activeConfigKey(gameType int) string {
+	return fmt.Sprintf("game_config:active:%d", gameType)
+}
+
+// GetActiveGameConfig 当前生效的配置（最新版本），没有配置时返回默认值
+func GetActiveGameConfig(ctx context.Context, gameType int) (*model.GameConfig, error) {
+	// 1. 先查 Redis（修改配置时会删除这个 Key），未命中或 Redis 异常都继续查数据库
+	cacheKey := activeConfigKey(gameType)
+	if val, err := myredis.RedisClient.Get(ctx, cacheKey).Result(); err == nil {
+		var cfg model.GameConfig
+		if jsonErr := json.Unmarshal([]byte(val), &cfg); jsonErr == nil {
+			return &cfg, nil
+		}
+	}
+
+	// 2. 查数据库最新版本
+	var configs []model.GameConfig
+	err := database.DB.WithContext(ctx).
+		Where("game_type = ?", gameType).
+		Order("version desc").
+		Limit(1).
+		Find(&configs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := DefaultGameConfig(gameType)
+	if len(configs) > 0 {
+		cfg = configs[0]
+		normalizeRakeRate(&cfg)
+	}
+
+	// 3. 回写缓存
+	data, _ := json.Marshal(cfg)
+	myredis.RedisClient.Set(ctx, cacheKey, data, time.Hour)
+
+	return &cfg, nil
+}
+
+// GetGameConfigVersion 按版本号读取配置，用于结算/复算历史局；版本 0 为默认配置
+func GetGameConfigVersion(ctx context.Context, gameType, version int) (*model.GameConfig, error) {
+	if version <= 0 {
+		cfg := DefaultGameConfig(gameType)
+		return &cfg, nil
+	}
+
+	key := fmt.Sprintf("%d:%d", gameType, version)
+	if cached, ok := configVersions.Load(key); ok {
+		cfg := cached.(model.GameConfig)
+		return &cfg, nil
+	}
+
+	var cfg model.GameConfig
+	err := database.DB.WithContext(ctx).
+		Where("game_type = ? AND version = ?", gameType, version).
+		First(&cfg).Error
+	if err != nil {
+		return nil, err
+	}
+	normalizeRakeRate(&cfg)
+	configVersions.Store(key, cfg)
+	return &cfg, nil
+}
+
+// GetDtsGameConfig 读取某一局大逃杀使用的配置
+func GetDtsGameConfig(ctx context.Context, dtsGame *model.LmDtsGame) (*model.GameConfig, error) {
+	return GetGameConfigVersion(ctx, game.TypeDts, dtsGame.ConfigVersion)
+}
+
+// UpdateGameConfig 发布新版本配置：以最新版本（没有则为默认值）为底稿，由 apply 修改字段，版本号 + 1
+// 已经开局的游戏仍然使用开局时的版本，新配置从下一局开始生效
+func UpdateGameConfig(ctx context.Context, gameType int, apply func(cfg *model.GameConfig), operator int64) (*model.GameConfig, error) {
+	var cfg model.GameConfig
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 锁住当前最新版本，防止两个管理员同时发布拿到同一个版本号、互相覆盖修改
+		var latest []model.GameConfig
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("game_type = ?", gameType).
+			Order("version desc").
+			Limit(1).
+			Find(&latest).Error; err != nil {
+			return err
+		}
+
+		cfg = DefaultGameConfig(gameType)
+		if len(latest) > 0 {
+			cfg = latest[0]
+			normalizeRakeRate(&cfg)
+		}
+		apply(&cfg)
+		// 只改了一部分字段时，要和沿用的旧值一起校验
+		if err := ValidateBetLimitConfig(&cfg); err != nil {
+			return err
+		}
+
+		cfg.Model = gorm.Model{}
+		cfg.GameType = gameType
+		cfg.Version++
+		cfg.Operator = operator
+		return tx.Create(&cfg).Error
+	})
+	if err != nil {
+		var bizErr *util.BizError
+		if errors.As(err, &bizErr) {
+			return nil, err
+		}
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+
+	myredis.RedisClient.Del(ctx, activeConfigKey(gameType))
+	return &cfg, nil
+}
+
+// ListGameConfigs 配置的历史版本（新版本在前）
+func ListGameConfigs(ctx context.Context, gameType int, req util.PaginationReq) ([]model.GameConfig, int64, error) {
+	var list []model.GameConfig
+	var total int64
+
+	db := database.DB.WithContext(ctx).Model(&model.GameConfig{}).Where("game_type = ?", gameType)
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, util.NewBizErr("SystemBusy", nil)
+	}
+	if err := db.Order("version desc").Offset(req.GetOffset()).Limit(req.GetSize()).Find(&list).Error; err != nil {
+		return nil, 0, util.NewBizErr("SystemBusy", nil)
+	}
+	for i := range list {
+		normalizeRakeRate(&list[i])
+	}
+	return list, total, nil
+}
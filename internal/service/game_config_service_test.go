@@ -0,0 +1,21 @@
+package service
+
+import (
+	"testing"
+
+	"test/internal/model"
+)
+
+func TestNormalizeRakeRate(t *testing.T) {
+	// 加抽水比例之前发布的版本按派奖比例换算
+	legacy := model.GameConfig{PayoutRate: 0.9}
+	normalizeRakeRate(&legacy)
+	if legacy.RakeRate != 0.1 {
+		t.Fatalf("legacy rake = %v, want 0.1", legacy.RakeRate)
+	}
+	noRake := model.GameConfig{PayoutRate: 1}
+	normalizeRakeRate(&noRake)
+	if noRake.RakeRate != 0 {
+		t.Fatalf("rake = %v, want 0", noRake.RakeRate)
+	}
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	myredis "test/pkg/redis"
+)
+
+// 分布式锁：值为每次加锁生成的随机 token，释放时只删除自己的锁
+// 持锁时间超过 TTL 后锁可能已经被别的节点拿走，直接 DEL 会把别人的锁删掉
+
+// unlockScript 比较 token 后再删除，两步必须原子
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// TryLock 尝试加锁，成功时返回释放锁要用的 token；锁被占用时返回空字符串
+func TryLock(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	token, err := newTxNo()
+	if err != nil {
+		return "", err
+	}
+	ok, err := myredis.RedisClient.SetNX(ctx, key, token, ttl).Result()
+	if err != nil || !ok {
+		return "", err
+	}
+	return token, nil
+}
+
+// Unlock 释放 TryLock 加的锁，锁已过期或者已经属于别人时什么都不做
+func Unlock(key, token string) {
+	_ = unlockScript.Run(context.Background(), myredis.RedisClient, []string{key}, token).Err()
+}
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"test/internal/model"
+	"test/pkg/database"
+	"test/pkg/money"
+	myredis "test/pkg/redis"
+	"test/pkg/util"
+)
+
+// 排行榜周期
+const (
+	RankDaily  = "daily"
+	RankWeekly = "weekly"
+	RankAll    = "all"
+)
+
+var RankPeriods = []string{RankDaily, RankWeekly, RankAll}
+
+// RankBucket 一个排行榜分桶：日榜按自然日、周榜按周一开始的自然周
+// Key 里带上日期，跨天/跨周自动落到新的 Key 上，旧 Key 过期后自动删除
+type RankBucket struct {
+	Period string
+	Name   string    // 20261018 / 2026W42 / all
+	Key    string    // Redis ZSet Key
+	Start  time.Time // 统计区间 [Start, End)，总榜为零值
+	End    time.Time
+	TTL    time.Duration // 0 表示不过期
+}
+
+// GetRankBucket 计算时间 t 所在的分桶
+func GetRankBucket(period string, t time.Time) (*RankBucket, error) {
+	t = t.In(time.Local)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+
+	bucket := &RankBucket{Period: period}
+	switch period {
+	case RankDaily:
+		bucket.Name = day.Format("20060102")
+		bucket.Start, bucket.End = day, day.AddDate(0, 0, 1)
+		bucket.TTL = 3 * 24 * time.Hour
+	case RankWeekly:
+		// 周一为一周的第一天
+		offset := (int(day.Weekday()) + 6) % 7
+		start := day.AddDate(0, 0, -offset)
+		year, week := start.ISOWeek()
+		bucket.Name = fmt.Sprintf("%dW%02d", year, week)
+		bucket.Start, bucket.End = start, start.AddDate(0, 0, 7)
+		bucket.TTL = 15 * 24 * time.Hour
+	case RankAll:
+		bucket.Name = "all"
+	default:
+		return nil, util.NewBizErr("RankPeriodInvalid", nil)
+	}
+	bucket.Key = fmt.Sprintf("dts_rank:%s:%s", period, bucket.Name)
+	return bucket, nil
+}
+
+// RecordRoundProfits 一局结算后把每个玩家的净盈亏累加到各周期的排行榜
+// 胜者为奖金（本金只是退回，不算盈利），被杀的为 -本金
+func RecordRoundProfits(ctx context.Context, settledAt time.Time, profits map[int64]money.Money) error {
+	if len(profits) == 0 {
+		return nil
+	}
+
+	pipe := myredis.RedisClient.TxPipeline()
+	for _, period := range RankPeriods {
+		bucket, err := GetRankBucket(period, settledAt)
+		if err != nil {
+			return err
+		}
+		for userID, profit := range profits {
+			pipe.ZIncrBy(ctx, bucket.Key, profit.Float64(), strconv.FormatInt(userID, 10))
+		}
+		if bucket.TTL > 0 {
+			pipe.Expire(ctx, bucket.Key, bucket.TTL)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RankItem 排行榜的一行
+type RankItem struct {
+	Rank     int64       `json:"rank"` // 从 1 开始，0 表示未上榜
+	UserID   int64       `json:"user_id"`
+	Nickname string      `json:"nickname"`
+	Profit   money.Money `json:"profit"` // Redis 分数是浮点数，读出来时四舍五入到分
+}
+
+// Leaderboard 排行榜：前 N 名 + 当前用户自己的名次
+type Leaderboard struct {
+	Period string     `json:"period"`
+	Bucket string     `json:"bucket"`
+	Items  []RankItem `json:"items"`
+	Mine   *RankItem  `json:"mine,omitempty"`
+}
+
+// GetLeaderboardTop 读取前 limit 名
+func GetLeaderboardTop(ctx context.Context, period string, limit int) (*Leaderboard, error) {
+	bucket, err := GetRankBucket(period, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := myredis.RedisClient.ZRevRangeWithScores(ctx, bucket.Key, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+
+	board := &Leaderboard{Period: period, Bucket: bucket.Name, Items: make([]RankItem, 0, len(members))}
+	userIDs := make([]int64, 0, len(members))
+	for i, member := range members {
+		userID, _ := strconv.ParseInt(member.Member.(string), 10, 64)
+		userIDs = append(userIDs, userID)
+		board.Items = append(board.Items, RankItem{
+			Rank:   int64(i + 1),
+			UserID: userID,
+			Profit: money.FromFloat(member.Score),
+		})
+	}
+
+	// 补充昵称
+	nicknames := getNicknames(ctx, userIDs)
+	for i := range board.Items {
+		board.Items[i].Nickname = nicknames[board.Items[i].UserID]
+	}
+	return board, nil
+}
+
+// GetUserRank 当前用户在某个周期的名次
+func GetUserRank(ctx context.Context, period string, userID int64) (*RankItem, error) {
+	bucket, err := GetRankBucket(period, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	member := strconv.FormatInt(userID, 10)
+	item := &RankItem{UserID: userID}
+
+	rank, err := myredis.RedisClient.ZRevRank(ctx, bucket.Key, member).Result()
+	if errors.Is(err, redis.Nil) {
+		return item, nil // 未上榜
+	}
+	if err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+	score, err := myredis.RedisClient.ZScore(ctx, bucket.Key, member).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+
+	item.Rank = rank + 1
+	item.Profit = money.FromFloat(score)
+	item.Nickname = getNicknames(ctx, []int64{userID})[userID]
+	return item, nil
+}
+
+// GetUsersRanks 批量查询用户在各周期的名次：所有周期一次 Pipeline，昵称一次查询
+// 结果按 周期 -> 用户 ID 组织，未上榜的用户名次为 0
+func GetUsersRanks(ctx context.Context, userIDs []int64) (map[string]map[int64]*RankItem, error) {
+	type rankCmds struct {
+		rank  *redis.IntCmd
+		score *redis.FloatCmd
+	}
+	cmds := make(map[string]map[int64]rankCmds, len(RankPeriods))
+	pipe := myredis.RedisClient.Pipeline()
+	for _, period := range RankPeriods {
+		bucket, err := GetRankBucket(period, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		cmds[period] = make(map[int64]rankCmds, len(userIDs))
+		for _, userID := range userIDs {
+			member := strconv.FormatInt(userID, 10)
+			cmds[period][userID] = rankCmds{
+				rank:  pipe.ZRevRank(ctx, bucket.Key, member),
+				score: pipe.ZScore(ctx, bucket.Key, member),
+			}
+		}
+	}
+	// 未上榜的成员返回 redis.Nil，按单条命令判断
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+
+	result := make(map[string]map[int64]*RankItem, len(cmds))
+	ranked := make([]int64, 0, len(userIDs))
+	seen := make(map[int64]bool, len(userIDs))
+	for period, users := range cmds {
+		result[period] = make(map[int64]*RankItem, len(users))
+		for userID, cmd := range users {
+			item := &RankItem{UserID: userID}
+			if rank, err := cmd.rank.Result(); err == nil {
+				item.Rank = rank + 1
+				item.Profit = money.FromFloat(cmd.score.Val())
+				if !seen[userID] {
+					seen[userID] = true
+					ranked = append(ranked, userID)
+				}
+			}
+			result[period][userID] = item
+		}
+	}
+
+	nicknames := getNicknames(ctx, ranked)
+	for _, users := range result {
+		for userID, item := range users {
+			if item.Rank > 0 {
+				item.Nickname = nicknames[userID]
+			}
+		}
+	}
+	return result, nil
+}
+
+// GetLeaderboard 前 N 名 + 调用者自己的名次
+func GetLeaderboard(ctx context.Context, period string, limit int, userID int64) (*Leaderboard, error) {
+	board, err := GetLeaderboardTop(ctx, period, limit)
+	if err != nil {
+		return nil, err
+	}
+	if userID > 0 {
+		if board.Mine, err = GetUserRank(ctx, period, userID); err != nil {
+			return nil, err
+		}
+	}
+	return board, nil
+}
+
+func getNicknames(ctx context.Context, userIDs []int64) map[int64]string {
+	nicknames := make(map[int64]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return nicknames
+	}
+	var users []model.User
+	database.DB.WithContext(ctx).Select("id", "nickname").Where("id IN ?", userIDs).Find(&users)
+	for _, user := range users {
+		nicknames[int64(user.ID)] = user.Nickname
+	}
+	return nicknames
+}
+
+// RebuildLeaderboard 从 lm_dts_record 重新计算某个周期的排行榜（Redis 被清空或数据修复后使用）
+// 先写临时 Key 再 RENAME，重建过程中读榜不会看到半成品
+func RebuildLeaderboard(ctx context.Context, period string, at time.Time) (*RankBucket, error) {
+	bucket, err := GetRankBucket(period, at)
+	if err != nil {
+		return nil, err
+	}
+
+	type userProfit struct {
+		UserId int64
+		Profit money.Money
+	}
+	var rows []userProfit
+
+	// 以游戏的结束时间（结算时间）归属分桶
+	query := database.DB.WithContext(ctx).
+		Table("lm_dts_record AS r").
+		Joins("JOIN lm_dts_game AS g ON g.id = r.game_id").
+		Select("r.user_id, SUM(CASE WHEN r.state = 1 THEN r.bonus WHEN r.state = 2 THEN -r.amount ELSE 0 END) AS profit").
+		Where("g.state = ? AND r.deleted_at IS NULL AND g.deleted_at IS NULL", 3)
+	if period != RankAll {
+		query = query.Where("g.end_time >= ? AND g.end_time < ?", bucket.Start.Unix(), bucket.End.Unix())
+	}
+	if err := query.Group("r.user_id").Scan(&rows).Error; err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+
+	tmpKey := bucket.Key + ":rebuild"
+	pipe := myredis.RedisClient.TxPipeline()
+	pipe.Del(ctx, tmpKey)
+	for _, row := range rows {
+		pipe.ZAdd(ctx, tmpKey, redis.Z{Score: row.Profit.Float64(), Member: strconv.FormatInt(row.UserId, 10)})
+	}
+	if len(rows) > 0 {
+		pipe.Rename(ctx, tmpKey, bucket.Key)
+		if bucket.TTL > 0 {
+			pipe.Expire(ctx, bucket.Key, bucket.TTL)
+		}
+	} else {
+		pipe.Del(ctx, bucket.Key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+	return bucket, nil
+}
+
+// EnsureLeaderboards 启动时检查：总榜不存在说明 Redis 被清空过，重建当前各周期的榜单
+func EnsureLeaderboards(ctx context.Context) error {
+	bucket, err := GetRankBucket(RankAll, time.Now())
+	if err != nil {
+		return err
+	}
+	exists, err := myredis.RedisClient.Exists(ctx, bucket.Key).Result()
+	if err != nil || exists > 0 {
+		return err
+	}
+	for _, period := range RankPeriods {
+		if _, err := RebuildLeaderboard(ctx, period, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
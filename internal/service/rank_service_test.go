@@ -0,0 +1,37 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetRankBucket(t *testing.T) {
+	// 2026-10-18 是周日，应当归属于 10-12(周一) 开始的那一周
+	at := time.Date(2026, 10, 18, 23, 59, 0, 0, time.Local)
+
+	daily, err := GetRankBucket(RankDaily, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if daily.Key != "dts_rank:daily:20261018" || !daily.End.Equal(daily.Start.AddDate(0, 0, 1)) {
+		t.Fatalf("unexpected daily bucket: %+v", daily)
+	}
+
+	weekly, err := GetRankBucket(RankWeekly, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weekly.Start.Weekday() != time.Monday || weekly.Start.Day() != 12 || weekly.Key != "dts_rank:weekly:2026W42" {
+		t.Fatalf("unexpected weekly bucket: %+v", weekly)
+	}
+
+	// 周一零点开始新的一周
+	next, _ := GetRankBucket(RankWeekly, weekly.End)
+	if next.Key == weekly.Key {
+		t.Fatalf("weekly bucket did not roll over: %s", next.Key)
+	}
+
+	if _, err := GetRankBucket("monthly", at); err == nil {
+		t.Fatal("expected error for unknown period")
+	}
+}
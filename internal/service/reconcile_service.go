@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"test/internal/model"
+	"test/pkg/database"
+	"test/pkg/money"
+	myredis "test/pkg/redis"
+	"test/pkg/util"
+)
+
+// 定时对账：下注在 Join 事务里扣款，派奖由发奖 Worker 异步入账，任务丢失或重复执行都不会报错
+// 所以定期把已结算的局和钱包流水逐局核对一遍，发现的差异写入 reconcile_discrepancies
+const (
+	ReconcileInterval  = 5 * time.Minute // 对账间隔
+	ReconcileDelay     = 2 * time.Minute // 结算后等多久再对账，给发奖 Worker 留出入账时间
+	ReconcileBatchSize = 200             // 每次最多对账的局数
+
+	reconcileCursorKey = "reconcile:dts:cursor" // 已对账到的最大游戏 ID，丢失后从头分批重扫
+	reconcileLockKey   = "reconcile:dts:lock"
+)
+
+// 差异状态
+const (
+	DiscrepancyOpen    int8 = 0 // 待处理
+	DiscrepancyFixed   int8 = 1 // 已修复：复查通过后系统自动关闭
+	DiscrepancyIgnored int8 = 2 // 已忽略：人工确认无需处理
+)
+
+// ReconcileDtsGame 对账一局已结算的大逃杀，返回本次发现的差异
+// 除了复算每条记录的输赢、奖金、抽水和派奖入账，还核对整局的下注流水和平台抽成：
+// 平台抽成 = 下注入账 - 派奖出账，应等于按开局配置版本复算的 house_revenue（杀手位总额 × 抽水比例 + 奖池拆分的零头）
+func ReconcileDtsGame(ctx context.Context, gameID uint) ([]model.ReconcileDiscrepancy, error) {
+	report, err := AuditDtsGame(ctx, gameID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	found := make([]model.ReconcileDiscrepancy, 0, len(report.Diffs))
+	add := func(recordID uint, field, expected, actual string) {
+		found = append(found, model.ReconcileDiscrepancy{
+			GameId:    int64(report.GameID),
+			RecordId:  int64(recordID),
+			Field:     field,
+			Expected:  expected,
+			Actual:    actual,
+			CheckedAt: now,
+		})
+	}
+	for _, diff := range report.Diffs {
+		add(diff.RecordID, diff.Field, diff.Expected, diff.Stored)
+	}
+
+	bets, err := ledgerByRecord(ctx, report.RecordIDs(), TxTypeBet)
+	if err != nil {
+		return nil, err
+	}
+	var betTotal, payoutTotal, bonusTotal money.Money
+	for _, rec := range report.Records {
+		bet := bets[rec.RecordID].Neg()
+		betTotal += bet
+		bonusTotal += rec.StoredBonus
+		if rec.LedgerPayout != nil {
+			payoutTotal += *rec.LedgerPayout
+		}
+		// 下注流水和下注记录的金额必须一致（追加下注会有多笔流水）
+		if bet != rec.Amount {
+			add(rec.RecordID, "ledger_bet", rec.Amount.String(), bet.String())
+		}
+	}
+
+	if betTotal != report.Stored.TotalAmount {
+		add(0, "bet_total", report.Stored.TotalAmount.String(), betTotal.String())
+	}
+	if bonusTotal != report.Stored.TotalBonus {
+		add(0, "bonus_total", report.Stored.TotalBonus.String(), bonusTotal.String())
+	}
+
+	// 平台留存按配置复算，不用库里的 house_revenue / total_bonus，否则两边一起算错时对不出来；金额精确到分，不留容差
+	expectedTake := report.Expected.HouseRevenue
+	houseTake := betTotal - payoutTotal
+	if houseTake != expectedTake {
+		add(0, "house_take", expectedTake.String(), houseTake.String())
+	}
+
+	if err := saveDiscrepancies(ctx, report.GameID, found, now); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// saveDiscrepancies 写入本局的对账结果：新差异插入、已有差异刷新，本次没再出现的待处理差异自动关闭
+func saveDiscrepancies(ctx context.Context, gameID uint, found []model.ReconcileDiscrepancy, now int64) error {
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []model.ReconcileDiscrepancy
+		if err := tx.Where("game_id = ?", gameID).Find(&existing).Error; err != nil {
+			return err
+		}
+		index := make(map[string]*model.ReconcileDiscrepancy, len(existing))
+		for i := range existing {
+			index[discrepancyKey(existing[i].RecordId, existing[i].Field)] = &existing[i]
+		}
+
+		seen := make(map[string]bool, len(found))
+		for i := range found {
+			item := &found[i]
+			key := discrepancyKey(item.RecordId, item.Field)
+			seen[key] = true
+
+			old, ok := index[key]
+			if !ok {
+				if err := tx.Create(item).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			updates := map[string]interface{}{
+				"expected":   item.Expected,
+				"actual":     item.Actual,
+				"checked_at": now,
+			}
+			// 修复后又出现的差异重新打开；人工忽略的保持忽略
+			if old.Status == DiscrepancyFixed {
+				updates["status"] = DiscrepancyOpen
+				updates["resolved_at"] = 0
+			}
+			if err := tx.Model(old).Updates(updates).Error; err != nil {
+				return err
+			}
+			// 返回带 ID 和处理状态的那一行
+			item.Model, item.Status, item.Remark, item.Operator = old.Model, old.Status, old.Remark, old.Operator
+			if old.Status == DiscrepancyFixed {
+				item.Status = DiscrepancyOpen
+			}
+		}
+
+		for _, old := range existing {
+			if old.Status != DiscrepancyOpen || seen[discrepancyKey(old.RecordId, old.Field)] {
+				continue
+			}
+			if err := tx.Model(&old).Updates(map[string]interface{}{
+				"status":      DiscrepancyFixed,
+				"checked_at":  now,
+				"resolved_at": now,
+				"operator":    0,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func discrepancyKey(recordID int64, field string) string {
+	return fmt.Sprintf("%d:%s", recordID, field)
+}
+
+// ReconcileResult 一次定时对账的结果
+type ReconcileResult struct {
+	Games         int `json:"games"`         // 对账的局数
+	Discrepancies int `json:"discrepancies"` // 本次发现的差异条数
+}
+
+// RunReconcile 定时对账：对账新结算的局，并复查仍有待处理差异的局
+// 多个节点同时运行时只有拿到锁的节点执行
+func RunReconcile(ctx context.Context) (*ReconcileResult, error) {
+	token, err := TryLock(ctx, reconcileLockKey, ReconcileInterval)
+	if err != nil || token == "" {
+		return nil, err
+	}
+	defer Unlock(reconcileLockKey, token)
+
+	cursor, _ := myredis.RedisClient.Get(ctx, reconcileCursorKey).Int64()
+
+	// 和补发派奖一样只看有下注流水的局：钱包流水上线之前结算的历史对局没有流水，核对只会得到一堆假的差异
+	betLedger := database.DB.Table("lm_dts_record AS r").
+		Joins("JOIN wallet_transactions AS w ON w.ref_type = ? AND w.ref_id = r.id AND w.type = ? AND w.user_id <> ?", RefTypeRecord, TxTypeBet, HouseAccountID).
+		Select("1").
+		Where("r.game_id = g.id")
+	var newIDs []uint
+	if err := database.DB.WithContext(ctx).Table("lm_dts_game AS g").
+		Where("g.state = ? AND g.id > ? AND g.end_time <= ? AND g.deleted_at IS NULL", 3, cursor, time.Now().Add(-ReconcileDelay).Unix()).
+		Where("EXISTS (?)", betLedger).
+		Order("g.id asc").
+		Limit(ReconcileBatchSize).
+		Pluck("g.id", &newIDs).Error; err != nil {
+		return nil, err
+	}
+
+	var openIDs []uint
+	if err := database.DB.WithContext(ctx).Model(&model.ReconcileDiscrepancy{}).
+		Where("status = ? AND game_id <= ?", DiscrepancyOpen, cursor).
+		Distinct("game_id").
+		Pluck("game_id", &openIDs).Error; err != nil {
+		return nil, err
+	}
+
+	result := &ReconcileResult{}
+	for _, id := range append(openIDs, newIDs...) {
+		found, err := ReconcileDtsGame(ctx, id)
+		if err != nil {
+			fmt.Printf("对账失败 game_id=%d: %v\n", id, err)
+			continue
+		}
+		result.Games++
+		result.Discrepancies += len(found)
+	}
+
+	if len(newIDs) > 0 {
+		myredis.RedisClient.Set(ctx, reconcileCursorKey, newIDs[len(newIDs)-1], 0)
+	}
+	return result, nil
+}
+
+// ListDiscrepancies 分页查看对账差异，status 为 nil 时不过滤状态，gameID 为 0 时不过滤游戏
+func ListDiscrepancies(ctx context.Context, status *int8, gameID uint, req util.PaginationReq) ([]model.ReconcileDiscrepancy, int64, error) {
+	db := database.DB.WithContext(ctx).Model(&model.ReconcileDiscrepancy{})
+	if status != nil {
+		db = db.Where("status = ?", *status)
+	}
+	if gameID > 0 {
+		db = db.Where("game_id = ?", gameID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, util.NewBizErr("SystemBusy", nil)
+	}
+	var list []model.ReconcileDiscrepancy
+	if err := db.Order("id desc").Offset(req.GetOffset()).Limit(req.GetSize()).Find(&list).Error; err != nil {
+		return nil, 0, util.NewBizErr("SystemBusy", nil)
+	}
+	return list, total, nil
+}
+
+// IgnoreDiscrepancy 人工确认差异无需处理
+func IgnoreDiscrepancy(ctx context.Context, id uint, remark string, operator int64) (*model.ReconcileDiscrepancy, error) {
+	var item model.ReconcileDiscrepancy
+	err := database.DB.WithContext(ctx).First(&item, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, util.NewBizErr("DiscrepancyNotFound", nil)
+	}
+	if err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+
+	item.Status = DiscrepancyIgnored
+	item.Remark = remark
+	item.Operator = operator
+	item.ResolvedAt = time.Now().Unix()
+	if err := database.DB.WithContext(ctx).Save(&item).Error; err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+	return &item, nil
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"test/internal/game"
+	"test/internal/model"
+	"test/internal/queue"
+	"test/pkg/database"
+)
+
+// RecoveryLockTTL 恢复期间持有结算锁的时长，补结算多局时比 ticker 的 10 秒长
+const RecoveryLockTTL = time.Minute
+
+// RecoveryBatchSize 每次最多补发的派奖任务数
+const RecoveryBatchSize = 500
+
+// CalcLockKey 每种玩法的结算锁，ticker 结算和崩溃恢复共用，保证同一时间只有一方在结算 / 开局
+func CalcLockKey(name string) string {
+	return fmt.Sprintf("game_%s_calc_lock", name)
+}
+
+// RecoveryReport 一次崩溃恢复做了什么
+type RecoveryReport struct {
+	SettledGames    []uint `json:"settled_games"`       // 倒计时已过仍停在 state 2、本次补结算的局
+	FailedGames     []uint `json:"failed_games"`        // 补结算失败的局，下次 tick 或下次恢复会再试
+	CreatedRound    bool   `json:"created_round"`       // 最新一局已结束且没有下一局，本次补开了一局
+	LastGameID      uint   `json:"last_game_id"`        // 恢复后的当前局
+	LastGameIDFixed bool   `json:"last_game_id_fixed"`  // Redis 里记录的当前局和数据库不一致，已修正
+	RequeuedRecords []uint `json:"requeued_records"`    // 判定为胜利但没有派奖流水、重新入队的记录
+	PaidNoLedger    []uint `json:"paid_without_payout"` // 标记为已派却找不到派奖流水，需要人工核查
+}
+
+// RecoverDts 启动时（以及后台手动）修复进程崩溃留下的半截状态：
+// 1. 倒计时已结束但仍是 state 2 的局：补结算
+// 2. 最新一局已结算 / 作废但没有下一局（calc 提交后、addGame 之前进程退出）：补开一局
+// 3. 胜利记录有下注流水、没有派奖流水（发奖任务丢在 Redis 里或入队前进程退出）：重新入队，派奖按 record_id 幂等
+// 与 ticker 共用结算锁，拿不到锁返回 nil
+func RecoverDts(ctx context.Context) (*RecoveryReport, error) {
+	engine, ok := game.Get(game.TypeDts)
+	if !ok {
+		return nil, fmt.Errorf("game engine %d not registered", game.TypeDts)
+	}
+
+	lockKey := CalcLockKey(engine.Name())
+	token, err := TryLock(ctx, lockKey, RecoveryLockTTL)
+	if err != nil || token == "" {
+		return nil, err
+	}
+	defer Unlock(lockKey, token)
+
+	report := &RecoveryReport{}
+
+	// 1. 补结算
+	dueIDs, err := engine.DueRounds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range dueIDs {
+		killerRoom, err := engine.Settle(ctx, id)
+		if err != nil {
+			fmt.Printf("[recovery] 补结算失败 game_id=%d: %v\n", id, err)
+			report.FailedGames = append(report.FailedGames, id)
+			continue
+		}
+		fmt.Printf("[recovery] 补结算 game_id=%d killer_room=%d\n", id, killerRoom)
+		report.SettledGames = append(report.SettledGames, id)
+	}
+
+	// 2. 补开下一局
+	if err := recoverDtsRound(ctx, engine, report); err != nil {
+		return nil, err
+	}
+
+	// 3. 补发奖金
+	if err := recoverDtsPayouts(ctx, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// recoverDtsRound 最新一局已经结束时开下一局，并修正 Redis 里的当前局
+func recoverDtsRound(ctx context.Context, engine game.Engine, report *RecoveryReport) error {
+	var latest model.LmDtsGame
+	result := database.DB.WithContext(ctx).Order("id desc").Limit(1).Find(&latest)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 || latest.State >= 3 {
+		// 上一局的杀手房间作为新局的 pre_killer_room，作废的局没有杀手房间时为 0
+		if err := engine.CreateRound(ctx, latest.KillerRoom); err != nil {
+			return err
+		}
+		report.CreatedRound = true
+		report.LastGameID, _ = GetLastGameId(ctx)
+		fmt.Printf("[recovery] 最新一局 game_id=%d state=%d 没有下一局，已开新局 game_id=%d\n", latest.ID, latest.State, report.LastGameID)
+		return nil
+	}
+
+	report.LastGameID = latest.ID
+	if lastID, _ := GetLastGameId(ctx); lastID != latest.ID {
+		SetLastGameId(ctx, latest.ID)
+		report.LastGameIDFixed = true
+		fmt.Printf("[recovery] 当前局 %d 与数据库最新一局 %d 不一致，已修正\n", lastID, latest.ID)
+	}
+	return nil
+}
+
+// recoverDtsPayouts 找出已结算局里没有派奖流水的胜利记录
+// paid = 0 的重新入队；paid = 1 说明标记和加钱没在一个事务里完成，只记录下来交给人工核查（排在后面，不挤占补发名额）
+// 只看有下注流水的记录：钱包流水上线之前的老记录当时直接改的余额，没有派奖流水不代表没派过
+// 已经进了死信的任务也跳过，它们是重试多次仍失败的，由人工查看原因后重放
+func recoverDtsPayouts(ctx context.Context, report *RecoveryReport) error {
+	deadIDs, err := queue.DeadRecordIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	ledger := func(txType string) *gorm.DB {
+		return database.DB.Model(&model.WalletTransaction{}).
+			Select("1").
+			Where("ref_type = ? AND ref_id = r.id AND type = ? AND user_id <> ?", RefTypeRecord, txType, HouseAccountID)
+	}
+	db := database.DB.WithContext(ctx).
+		Table("lm_dts_record AS r").
+		Joins("JOIN lm_dts_game AS g ON g.id = r.game_id").
+		Select("r.*").
+		Where("g.state = ? AND r.state = ? AND r.deleted_at IS NULL", 3, 1).
+		Where("EXISTS (?)", ledger(TxTypeBet)).
+		Where("NOT EXISTS (?)", ledger(TxTypePayout))
+	if len(deadIDs) > 0 {
+		db = db.Where("r.id NOT IN ?", deadIDs)
+	}
+
+	var records []model.LmDtsRecord
+	err = db.Order("r.paid asc, r.id asc").
+		Limit(RecoveryBatchSize).
+		Find(&records).Error
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if record.Paid == 1 {
+			fmt.Printf("[recovery] 记录已标记派奖但没有派奖流水 record_id=%d user_id=%d\n", record.ID, record.UserId)
+			report.PaidNoLedger = append(report.PaidNoLedger, record.ID)
+			continue
+		}
+
+		// 与结算时入队的金额一致：本金 + 奖金，见 SettleDts
+		payout := record.Amount + record.Bonus
+		if err := queue.Push(ctx, queue.BonusJob{
+			RecordID: record.ID,
+			UserID:   record.UserId,
+			Amount:   payout,
+		}); err != nil {
+			return err
+		}
+		fmt.Printf("[recovery] 重新入队发奖任务 record_id=%d user_id=%d amount=%s\n", record.ID, record.UserId, payout)
+		report.RequeuedRecords = append(report.RequeuedRecords, record.ID)
+	}
+	return nil
+}
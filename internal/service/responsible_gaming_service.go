@@ -0,0 +1,462 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"test/internal/model"
+	"test/pkg/config"
+	"test/pkg/database"
+	"test/pkg/money"
+	"test/pkg/util"
+)
+
+// 负责任博彩：玩家自己设置下注 / 亏损上限、冷静期和自我排除，在下注事务里强制校验
+// 收紧限制立即生效，放宽限制要等 IncreaseDelayHours 之后才生效；每一次修改都写审计记录
+const (
+	LimitDaily   = "daily"
+	LimitWeekly  = "weekly"
+	LimitMonthly = "monthly"
+
+	GamingLogPending   int8 = 0 // 待生效
+	GamingLogApplied   int8 = 1 // 已生效
+	GamingLogCancelled int8 = 2 // 已取消：被之后的修改取代
+
+	// 冷静期和自我排除的审计字段
+	GamingFieldCoolOff   = "cool_off"
+	GamingFieldExclusion = "self_exclusion"
+
+	PermanentExclusion int64 = -1 // 永久自我排除
+)
+
+var limitPeriods = []string{LimitDaily, LimitWeekly, LimitMonthly}
+
+// 超限提示按周期区分，方便翻译
+var (
+	wagerLimitKeys = map[string]string{LimitDaily: "DailyWagerLimitReached", LimitWeekly: "WeeklyWagerLimitReached", LimitMonthly: "MonthlyWagerLimitReached"}
+	lossLimitKeys  = map[string]string{LimitDaily: "DailyLossLimitReached", LimitWeekly: "WeeklyLossLimitReached", LimitMonthly: "MonthlyLossLimitReached"}
+)
+
+// gamingLimitFields 玩家可以修改的限制项
+var gamingLimitFields = map[string]func(l *model.UserGamingLimit) *money.Money{
+	"daily_wager":   func(l *model.UserGamingLimit) *money.Money { return &l.DailyWager },
+	"weekly_wager":  func(l *model.UserGamingLimit) *money.Money { return &l.WeeklyWager },
+	"monthly_wager": func(l *model.UserGamingLimit) *money.Money { return &l.MonthlyWager },
+	"daily_loss":    func(l *model.UserGamingLimit) *money.Money { return &l.DailyLoss },
+	"weekly_loss":   func(l *model.UserGamingLimit) *money.Money { return &l.WeeklyLoss },
+	"monthly_loss":  func(l *model.UserGamingLimit) *money.Money { return &l.MonthlyLoss },
+}
+
+// GamingUsage 一个周期内的下注额和净亏损（亏损为正数，盈利时为负数）
+type GamingUsage struct {
+	Wager money.Money `json:"wager"`
+	Loss  money.Money `json:"loss"`
+}
+
+// LimitPeriodStart 周期的开始时间：自然日、周一开始的自然周、自然月
+func LimitPeriodStart(period string, now time.Time) time.Time {
+	now = now.In(time.Local)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	switch period {
+	case LimitWeekly:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case LimitMonthly:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	default:
+		return day
+	}
+}
+
+// IsLimitIncrease 修改金额上限是否为放宽：取消限制（改为 0）或者调高上限
+func IsLimitIncrease(oldValue, newValue money.Money) bool {
+	if newValue == 0 {
+		return oldValue > 0
+	}
+	return oldValue > 0 && newValue > oldValue
+}
+
+// periodLimits 某个周期的下注上限和亏损上限
+func periodLimits(l *model.UserGamingLimit, period string) (wager, loss money.Money) {
+	switch period {
+	case LimitWeekly:
+		return l.WeeklyWager, l.WeeklyLoss
+	case LimitMonthly:
+		return l.MonthlyWager, l.MonthlyLoss
+	default:
+		return l.DailyWager, l.DailyLoss
+	}
+}
+
+// CheckGamingRestrictions 自我排除和冷静期
+func CheckGamingRestrictions(l *model.UserGamingLimit, now time.Time) error {
+	if l.ExcludedUntil == PermanentExclusion {
+		return util.NewBizErr("SelfExcludedPermanent", nil)
+	}
+	if l.ExcludedUntil > now.Unix() {
+		return util.NewBizErr("SelfExcluded", map[string]interface{}{"Until": formatLimitTime(l.ExcludedUntil)})
+	}
+	if l.CoolOffUntil > now.Unix() {
+		return util.NewBizErr("CoolOffActive", map[string]interface{}{"Until": formatLimitTime(l.CoolOffUntil)})
+	}
+	return nil
+}
+
+// CheckGamingUsage 本次下注后各周期的下注额 / 最坏情况下的亏损（本金全输）是否超过上限
+func CheckGamingUsage(l *model.UserGamingLimit, usage map[string]GamingUsage, amount money.Money) error {
+	for _, period := range limitPeriods {
+		wagerLimit, lossLimit := periodLimits(l, period)
+		used := usage[period]
+		if wagerLimit > 0 && used.Wager+amount > wagerLimit {
+			return util.NewBizErr(wagerLimitKeys[period], map[string]interface{}{"Limit": wagerLimit.String(), "Used": used.Wager.String()})
+		}
+		if lossLimit > 0 && used.Loss+amount > lossLimit {
+			return util.NewBizErr(lossLimitKeys[period], map[string]interface{}{"Limit": lossLimit.String(), "Used": used.Loss.String()})
+		}
+	}
+	return nil
+}
+
+func formatLimitTime(ts int64) string {
+	return time.Unix(ts, 0).In(time.Local).Format("2006-01-02 15:04")
+}
+
+// CheckGamingLimits 下注、换房事务里调用（用户行已加锁），校验自我排除、冷静期和各周期的上限
+func CheckGamingLimits(tx *gorm.DB, userID int64, amount money.Money) error {
+	var limits []model.UserGamingLimit
+	if err := tx.Where("user_id = ?", userID).Limit(1).Find(&limits).Error; err != nil {
+		return err
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+	limit := &limits[0]
+	now := time.Now()
+	if err := applyDueGamingChanges(tx, limit, now); err != nil {
+		return err
+	}
+	if err := CheckGamingRestrictions(limit, now); err != nil {
+		return err
+	}
+
+	usage, err := gamingUsage(tx, userID, limit, now)
+	if err != nil {
+		return err
+	}
+	return CheckGamingUsage(limit, usage, amount)
+}
+
+// gamingUsage 按钱包流水统计各周期的下注额和净亏损，只统计设置了上限的周期
+// 下注额 = 下注 - 退款；净亏损 = -(下注 + 派奖 + 退款)，未结算的下注按全输计算
+func gamingUsage(tx *gorm.DB, userID int64, limit *model.UserGamingLimit, now time.Time) (map[string]GamingUsage, error) {
+	usage := make(map[string]GamingUsage, len(limitPeriods))
+	for _, period := range limitPeriods {
+		wagerLimit, lossLimit := periodLimits(limit, period)
+		if wagerLimit == 0 && lossLimit == 0 {
+			continue
+		}
+		used, err := walletUsage(tx, userID, LimitPeriodStart(period, now))
+		if err != nil {
+			return nil, err
+		}
+		usage[period] = used
+	}
+	return usage, nil
+}
+
+// walletUsage 从 since 开始的下注额和净亏损（换房手续费只算亏损，不算下注额）
+func walletUsage(db *gorm.DB, userID int64, since time.Time) (GamingUsage, error) {
+	var row struct {
+		Wager money.Money
+		Net   money.Money
+	}
+	err := db.Model(&model.WalletTransaction{}).
+		Select("SUM(CASE WHEN type IN ? THEN amount ELSE 0 END) as wager, SUM(amount) as net", []string{TxTypeBet, TxTypeRefund}).
+		Where("user_id = ? AND type IN ? AND created_at >= ?", userID, []string{TxTypeBet, TxTypePayout, TxTypeRefund, TxTypeSwitchFee, TxTypeReversal}, since).
+		Scan(&row).Error
+	return GamingUsage{Wager: row.Wager.Neg(), Loss: row.Net.Neg()}, err
+}
+
+// pendingGamingChanges 用户等待生效的放宽修改，按提交顺序
+func pendingGamingChanges(db *gorm.DB, userID int64) ([]model.UserGamingLimitLog, error) {
+	pending := make([]model.UserGamingLimitLog, 0)
+	err := db.Where("user_id = ? AND status = ?", userID, GamingLogPending).Order("id asc").Find(&pending).Error
+	return pending, err
+}
+
+// mergeDueGamingChanges 把等待期已过的修改合并到 limit（只改内存），返回已合并的和仍在等待的
+func mergeDueGamingChanges(limit *model.UserGamingLimit, pending []model.UserGamingLimitLog, now time.Time) ([]model.UserGamingLimitLog, []model.UserGamingLimitLog) {
+	var due []model.UserGamingLimitLog
+	waiting := make([]model.UserGamingLimitLog, 0, len(pending))
+	for _, change := range pending {
+		if change.EffectiveAt > now.Unix() {
+			waiting = append(waiting, change)
+			continue
+		}
+		due = append(due, change)
+		field, ok := gamingLimitFields[change.Field]
+		if !ok {
+			continue
+		}
+		value, err := money.Parse(change.NewValue)
+		if err != nil {
+			continue
+		}
+		*field(limit) = value
+	}
+	return due, waiting
+}
+
+// applyDueGamingChanges 把等待期已过的放宽修改应用到限制上并落库
+func applyDueGamingChanges(tx *gorm.DB, limit *model.UserGamingLimit, now time.Time) error {
+	pending, err := pendingGamingChanges(tx, limit.UserId)
+	if err != nil {
+		return err
+	}
+	due, _ := mergeDueGamingChanges(limit, pending, now)
+	if len(due) == 0 {
+		return nil
+	}
+	ids := make([]uint, 0, len(due))
+	for _, change := range due {
+		ids = append(ids, change.ID)
+	}
+	if err := tx.Model(&model.UserGamingLimitLog{}).Where("id IN ?", ids).Update("status", GamingLogApplied).Error; err != nil {
+		return err
+	}
+	return tx.Save(limit).Error
+}
+
+// lockGamingLimit 读取并锁住用户的限制行，没有时创建
+func lockGamingLimit(tx *gorm.DB, userID int64) (*model.UserGamingLimit, error) {
+	// 先锁用户行，防止两个请求同时为同一用户创建限制行
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&model.User{}, userID).Error; err != nil {
+		return nil, err
+	}
+	var limit model.UserGamingLimit
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&limit).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		limit = model.UserGamingLimit{UserId: userID}
+		err = tx.Create(&limit).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+// GamingLimitView 玩家查看自己的限制
+type GamingLimitView struct {
+	Limit               model.UserGamingLimit      `json:"limit"`
+	RealityCheckMinutes int                        `json:"reality_check_minutes"` // 实际使用的提醒间隔（含系统默认）
+	Pending             []model.UserGamingLimitLog `json:"pending"`               // 等待生效的放宽修改
+	Usage               map[string]GamingUsage     `json:"usage"`                 // 各周期已用额度（只含设置了上限的周期）
+}
+
+// GetGamingLimits 当前的限制、待生效的修改和已用额度
+// 只读：没有限制行时按默认值（不限制）返回，等待期已过的修改只在返回结果里合并，下注或修改时才落库
+func GetGamingLimits(ctx context.Context, userID int64) (*GamingLimitView, error) {
+	db := database.DB.WithContext(ctx)
+	limit := model.UserGamingLimit{UserId: userID}
+	var limits []model.UserGamingLimit
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&limits).Error; err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+	if len(limits) > 0 {
+		limit = limits[0]
+	}
+
+	pending, err := pendingGamingChanges(db, userID)
+	if err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+	now := time.Now()
+	_, waiting := mergeDueGamingChanges(&limit, pending, now)
+
+	view := &GamingLimitView{
+		Limit:               limit,
+		RealityCheckMinutes: effectiveRealityCheck(&limit),
+		Pending:             waiting,
+	}
+	if view.Usage, err = gamingUsage(db, userID, &limit, now); err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+	return view, nil
+}
+
+// UpdateGamingLimits 修改金额上限和提醒间隔，values 的 key 为 gamingLimitFields 里的字段
+func UpdateGamingLimits(ctx context.Context, userID int64, values map[string]money.Money, realityCheck *int, ip string) (*GamingLimitView, error) {
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		limit, err := lockGamingLimit(tx, userID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := applyDueGamingChanges(tx, limit, now); err != nil {
+			return err
+		}
+		delay := time.Duration(config.Conf.ResponsibleGaming.IncreaseDelayHours) * time.Hour
+
+		for name, value := range values {
+			field, ok := gamingLimitFields[name]
+			if !ok {
+				continue
+			}
+			// 同一项之前还没生效的修改作废，以这一次为准
+			if err := tx.Model(&model.UserGamingLimitLog{}).
+				Where("user_id = ? AND field = ? AND status = ?", userID, name, GamingLogPending).
+				Update("status", GamingLogCancelled).Error; err != nil {
+				return err
+			}
+			current := *field(limit)
+			if value == current {
+				continue
+			}
+
+			entry := model.UserGamingLimitLog{
+				UserId:      userID,
+				Field:       name,
+				OldValue:    current.String(),
+				NewValue:    value.String(),
+				Status:      GamingLogApplied,
+				EffectiveAt: now.Unix(),
+				Ip:          ip,
+			}
+			if IsLimitIncrease(current, value) {
+				entry.Status = GamingLogPending
+				entry.EffectiveAt = now.Add(delay).Unix()
+			} else {
+				*field(limit) = value
+			}
+			if err := tx.Create(&entry).Error; err != nil {
+				return err
+			}
+		}
+
+		if realityCheck != nil && *realityCheck != limit.RealityCheckMinutes {
+			if err := tx.Create(&model.UserGamingLimitLog{
+				UserId:      userID,
+				Field:       "reality_check_minutes",
+				OldValue:    strconv.Itoa(limit.RealityCheckMinutes),
+				NewValue:    strconv.Itoa(*realityCheck),
+				Status:      GamingLogApplied,
+				EffectiveAt: now.Unix(),
+				Ip:          ip,
+			}).Error; err != nil {
+				return err
+			}
+			limit.RealityCheckMinutes = *realityCheck
+		}
+		return tx.Save(limit).Error
+	})
+	if err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+	return GetGamingLimits(ctx, userID)
+}
+
+// SetCoolOff 开启冷静期，已有更长的冷静期时保持不变；冷静期不能提前结束
+func SetCoolOff(ctx context.Context, userID int64, days int, ip string) (*GamingLimitView, error) {
+	until := time.Now().AddDate(0, 0, days).Unix()
+	if err := extendRestriction(ctx, userID, GamingFieldCoolOff, until, ip); err != nil {
+		return nil, err
+	}
+	return GetGamingLimits(ctx, userID)
+}
+
+// SelfExclude 自我排除，days 为 0 表示永久；排除期间不能下注，也不能提前解除
+func SelfExclude(ctx context.Context, userID int64, days int, ip string) (*GamingLimitView, error) {
+	until := PermanentExclusion
+	if days > 0 {
+		until = time.Now().AddDate(0, 0, days).Unix()
+	}
+	if err := extendRestriction(ctx, userID, GamingFieldExclusion, until, ip); err != nil {
+		return nil, err
+	}
+	return GetGamingLimits(ctx, userID)
+}
+
+// extendRestriction 只允许延长冷静期 / 自我排除，缩短的请求忽略
+func extendRestriction(ctx context.Context, userID int64, field string, until int64, ip string) error {
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		limit, err := lockGamingLimit(tx, userID)
+		if err != nil {
+			return err
+		}
+		current := &limit.CoolOffUntil
+		if field == GamingFieldExclusion {
+			current = &limit.ExcludedUntil
+		}
+		if *current == PermanentExclusion || (until != PermanentExclusion && until <= *current) {
+			return nil
+		}
+
+		if err := tx.Create(&model.UserGamingLimitLog{
+			UserId:      userID,
+			Field:       field,
+			OldValue:    strconv.FormatInt(*current, 10),
+			NewValue:    strconv.FormatInt(until, 10),
+			Status:      GamingLogApplied,
+			EffectiveAt: time.Now().Unix(),
+			Ip:          ip,
+		}).Error; err != nil {
+			return err
+		}
+		*current = until
+		return tx.Save(limit).Error
+	})
+	if err != nil {
+		return util.NewBizErr("SystemBusy", nil)
+	}
+	return nil
+}
+
+// ListGamingLimitLogs 限制的修改记录
+func ListGamingLimitLogs(ctx context.Context, userID int64, req util.PaginationReq) ([]model.UserGamingLimitLog, int64, error) {
+	db := database.DB.WithContext(ctx).Model(&model.UserGamingLimitLog{}).Where("user_id = ?", userID)
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, util.NewBizErr("SystemBusy", nil)
+	}
+	var list []model.UserGamingLimitLog
+	if err := db.Order("id desc").Offset(req.GetOffset()).Limit(req.GetSize()).Find(&list).Error; err != nil {
+		return nil, 0, util.NewBizErr("SystemBusy", nil)
+	}
+	return list, total, nil
+}
+
+func effectiveRealityCheck(limit *model.UserGamingLimit) int {
+	if limit != nil && limit.RealityCheckMinutes > 0 {
+		return limit.RealityCheckMinutes
+	}
+	return config.Conf.ResponsibleGaming.RealityCheckMinutes
+}
+
+// RealityCheckInterval 用户的游戏时长提醒间隔，0 表示不提醒
+func RealityCheckInterval(ctx context.Context, userID int64) time.Duration {
+	var limits []model.UserGamingLimit
+	_ = database.DB.WithContext(ctx).Where("user_id = ?", userID).Limit(1).Find(&limits).Error
+	var limit *model.UserGamingLimit
+	if len(limits) > 0 {
+		limit = &limits[0]
+	}
+	return time.Duration(effectiveRealityCheck(limit)) * time.Minute
+}
+
+// RealityCheckMessage 游戏时长提醒：本次连接的时长，以及这段时间的下注额和输赢
+func RealityCheckMessage(ctx context.Context, userID int64, since time.Time) (map[string]interface{}, error) {
+	used, err := walletUsage(database.DB.WithContext(ctx), userID, since)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"type":            "reality_check",
+		"session_seconds": int64(time.Since(since).Seconds()),
+		"wagered":         used.Wager.String(),
+		"net":             used.Loss.Neg().String(), // 正数为赢，负数为输
+		"timestamp":       time.Now().Unix(),
+	}, nil
+}
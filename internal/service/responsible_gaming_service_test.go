@@ -0,0 +1,108 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"test/internal/model"
+	"test/pkg/money"
+	"test/pkg/util"
+)
+
+func bizKey(err error) string {
+	var bizErr *util.BizError
+	if errors.As(err, &bizErr) {
+		return bizErr.Key
+	}
+	return ""
+}
+
+func TestLimitPeriodStart(t *testing.T) {
+	// 2026-10-18 是周日
+	now := time.Date(2026, 10, 18, 15, 30, 0, 0, time.Local)
+	if got := LimitPeriodStart(LimitDaily, now); !got.Equal(time.Date(2026, 10, 18, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("daily: %v", got)
+	}
+	if got := LimitPeriodStart(LimitWeekly, now); !got.Equal(time.Date(2026, 10, 12, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("weekly: %v", got)
+	}
+	if got := LimitPeriodStart(LimitMonthly, now); !got.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("monthly: %v", got)
+	}
+}
+
+func TestIsLimitIncrease(t *testing.T) {
+	cases := []struct {
+		old, new money.Money
+		want     bool
+	}{
+		{0, money.FromYuan(100), false},                     // 新设限制是收紧
+		{money.FromYuan(100), money.FromYuan(50), false},    // 调低
+		{money.FromYuan(100), money.FromCents(10001), true}, // 调高
+		{money.FromYuan(100), 0, true},                      // 取消限制
+		{0, 0, false},
+	}
+	for _, c := range cases {
+		if got := IsLimitIncrease(c.old, c.new); got != c.want {
+			t.Errorf("IsLimitIncrease(%v, %v) = %v", c.old, c.new, got)
+		}
+	}
+}
+
+func TestCheckGamingRestrictions(t *testing.T) {
+	now := time.Now()
+	if err := CheckGamingRestrictions(&model.UserGamingLimit{CoolOffUntil: now.Add(-time.Minute).Unix()}, now); err != nil {
+		t.Fatalf("expired cool-off should pass: %v", err)
+	}
+	if key := bizKey(CheckGamingRestrictions(&model.UserGamingLimit{CoolOffUntil: now.Add(time.Hour).Unix()}, now)); key != "CoolOffActive" {
+		t.Fatalf("got %q", key)
+	}
+	if key := bizKey(CheckGamingRestrictions(&model.UserGamingLimit{ExcludedUntil: now.Add(time.Hour).Unix()}, now)); key != "SelfExcluded" {
+		t.Fatalf("got %q", key)
+	}
+	if key := bizKey(CheckGamingRestrictions(&model.UserGamingLimit{ExcludedUntil: PermanentExclusion}, now)); key != "SelfExcludedPermanent" {
+		t.Fatalf("got %q", key)
+	}
+}
+
+func TestCheckGamingUsage(t *testing.T) {
+	limit := &model.UserGamingLimit{DailyWager: money.FromYuan(100), WeeklyLoss: money.FromYuan(50)}
+	usage := map[string]GamingUsage{
+		LimitDaily:  {Wager: money.FromYuan(80), Loss: money.FromYuan(80)},
+		LimitWeekly: {Wager: money.FromYuan(300), Loss: money.FromYuan(30)},
+	}
+	if err := CheckGamingUsage(limit, usage, money.FromYuan(20)); err != nil {
+		t.Fatalf("exactly at limit should pass: %v", err)
+	}
+	if key := bizKey(CheckGamingUsage(limit, usage, money.FromCents(2001))); key != "DailyWagerLimitReached" {
+		t.Fatalf("got %q", key)
+	}
+
+	// 本周已经赢钱时亏损额度为负，可以多下一些
+	usage[LimitWeekly] = GamingUsage{Loss: money.FromYuan(-40)}
+	limit.DailyWager = 0
+	if err := CheckGamingUsage(limit, usage, money.FromYuan(90)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if key := bizKey(CheckGamingUsage(limit, usage, money.FromCents(9001))); key != "WeeklyLossLimitReached" {
+		t.Fatalf("got %q", key)
+	}
+}
+
+func TestMergeDueGamingChanges(t *testing.T) {
+	now := time.Now()
+	limit := &model.UserGamingLimit{DailyLoss: money.FromYuan(100)}
+	pending := []model.UserGamingLimitLog{
+		{Field: "daily_loss", NewValue: "500", EffectiveAt: now.Add(-time.Hour).Unix()},
+		{Field: "daily_wager", NewValue: "800", EffectiveAt: now.Add(time.Hour).Unix()},
+	}
+	due, waiting := mergeDueGamingChanges(limit, pending, now)
+	if len(due) != 1 || len(waiting) != 1 || waiting[0].Field != "daily_wager" {
+		t.Fatalf("due = %v, waiting = %v", due, waiting)
+	}
+	// 到期的修改合并到限制上，还没到期的不能提前生效
+	if limit.DailyLoss != money.FromYuan(500) || limit.DailyWager != 0 {
+		t.Fatalf("limit = %+v", limit)
+	}
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"test/internal/game"
+	"test/internal/model"
+	"test/pkg/database"
+	"test/pkg/money"
+	"test/pkg/util"
+)
+
+// 营收报表：按结算时间（lm_dts_game.end_time）把已结算的对局汇总到自然日
+// 每一行都是从对局重新算出来的，结算、作废之后刷新当天，历史数据可以用重算接口补
+const (
+	revenueDateLayout = "2006-01-02"
+	MaxRevenueDays    = 366 // 一次查询 / 重算最多覆盖的天数
+)
+
+// ParseRevenueRange 解析 [start, end] 两个日期（含首尾），返回 [开始那天 0 点, 结束次日 0 点)
+func ParseRevenueRange(start, end string) (time.Time, time.Time, error) {
+	from, err := time.ParseInLocation(revenueDateLayout, start, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, util.NewBizErr("InvalidDateRange", nil)
+	}
+	to, err := time.ParseInLocation(revenueDateLayout, end, time.Local)
+	if err != nil || to.Before(from) {
+		return time.Time{}, time.Time{}, util.NewBizErr("InvalidDateRange", nil)
+	}
+	to = to.AddDate(0, 0, 1)
+	if to.After(from.AddDate(0, 0, MaxRevenueDays)) {
+		return time.Time{}, time.Time{}, util.NewBizErr("DateRangeTooLong", map[string]interface{}{"Max": MaxRevenueDays})
+	}
+	return from, to, nil
+}
+
+// revenueStats 一段时间内已结算对局的汇总
+type revenueStats struct {
+	Rounds     int64
+	Bets       money.Money
+	Payouts    money.Money
+	SwitchFees money.Money
+	Players    int64
+}
+
+// Ggr 平台收入：结算时留存的 house_revenue（抽水 + 零头）+ 换房手续费
+func (s revenueStats) Ggr() money.Money {
+	return s.Bets - s.Payouts + s.SwitchFees
+}
+
+// dtsRevenueStats 汇总 [from, to) 之间结算的大逃杀对局
+// 派奖 = 总下注额 - 结算时记下的平台收入（house_revenue），与结算入账的口径一致，不在这里重新推算
+// 加这个字段之前结算的局 rake_rate、house_revenue 都是 0，只有这些局按 杀手位总额 - 总奖金 计算（对账核对的就是两者相等）
+// 换房手续费也是平台收入，按所在对局的结算日期归属；作废的局手续费已退回，不计入
+func dtsRevenueStats(db *gorm.DB, from, to time.Time) (revenueStats, error) {
+	var stats revenueStats
+	settled := func() *gorm.DB {
+		return db.Table("lm_dts_game AS g").
+			Where("g.state = ? AND g.end_time >= ? AND g.end_time < ? AND g.deleted_at IS NULL", 3, from.Unix(), to.Unix())
+	}
+
+	err := settled().
+		Select("COUNT(*) AS rounds, SUM(g.total_amount) AS bets, SUM(g.total_amount - CASE WHEN g.rake_rate = 0 AND g.house_revenue = 0 THEN g.total_killer_amount - g.total_bonus ELSE g.house_revenue END) AS payouts").
+		Scan(&stats).Error
+	if err != nil {
+		return stats, err
+	}
+	// 手续费流水是负数（玩家出账）
+	err = settled().
+		Joins("JOIN lm_dts_record AS r ON r.game_id = g.id AND r.deleted_at IS NULL").
+		Joins("JOIN wallet_transactions AS w ON w.ref_type = ? AND w.ref_id = r.id AND w.type = ? AND w.user_id <> ? AND w.deleted_at IS NULL", RefTypeRecord, TxTypeSwitchFee, HouseAccountID).
+		Select("COALESCE(-SUM(w.amount), 0)").
+		Scan(&stats.SwitchFees).Error
+	if err != nil {
+		return stats, err
+	}
+	err = settled().
+		Joins("JOIN lm_dts_record AS r ON r.game_id = g.id AND r.deleted_at IS NULL").
+		Select("COUNT(DISTINCT r.user_id)").
+		Scan(&stats.Players).Error
+	return stats, err
+}
+
+// RefreshDtsDailyRevenue 重新汇总 day 那一天的大逃杀营收并写入 daily_revenues
+func RefreshDtsDailyRevenue(ctx context.Context, day time.Time) (*model.DailyRevenue, error) {
+	from := LimitPeriodStart(LimitDaily, day)
+	stats, err := dtsRevenueStats(database.DB.WithContext(ctx), from, from.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	row := &model.DailyRevenue{
+		Date:          from.Format(revenueDateLayout),
+		GameType:      game.TypeDts,
+		Rounds:        stats.Rounds,
+		Bets:          stats.Bets,
+		Payouts:       stats.Payouts,
+		SwitchFees:    stats.SwitchFees,
+		Ggr:           stats.Ggr(),
+		ActivePlayers: stats.Players,
+	}
+	err = database.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "date"}, {Name: "game_type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rounds", "bets", "payouts", "switch_fees", "ggr", "active_players", "updated_at"}),
+	}).Create(row).Error
+	if err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// RebuildDailyRevenue 逐天重算 [start, end] 的营收（上线前的历史数据、修数之后使用）
+func RebuildDailyRevenue(ctx context.Context, start, end string) ([]model.DailyRevenue, error) {
+	from, to, err := ParseRevenueRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]model.DailyRevenue, 0)
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		row, err := RefreshDtsDailyRevenue(ctx, day)
+		if err != nil {
+			return nil, util.NewBizErr("SystemBusy", nil)
+		}
+		rows = append(rows, *row)
+	}
+	return rows, nil
+}
+
+// RevenueSummary 整个日期范围的合计
+type RevenueSummary struct {
+	Rounds        int64       `json:"rounds"`
+	Bets          money.Money `json:"bets"`
+	Payouts       money.Money `json:"payouts"`
+	SwitchFees    money.Money `json:"switch_fees"`
+	Ggr           money.Money `json:"ggr"`
+	ActivePlayers int64       `json:"active_players"` // 整个范围内去重的玩家数，不是每天相加
+}
+
+// RevenueReport 营收报表
+type RevenueReport struct {
+	StartDate string               `json:"start_date"`
+	EndDate   string               `json:"end_date"`
+	Days      []model.DailyRevenue `json:"days"` // 只包含已经汇总过的日期
+	Summary   RevenueSummary       `json:"summary"`
+}
+
+// GetRevenueReport 按日期范围查询营收，gameType 为 0 表示全部游戏
+func GetRevenueReport(ctx context.Context, gameType int, start, end string) (*RevenueReport, error) {
+	from, to, err := ParseRevenueRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RevenueReport{StartDate: start, EndDate: end, Days: []model.DailyRevenue{}}
+	db := database.DB.WithContext(ctx).
+		Where("date >= ? AND date <= ?", start, end)
+	if gameType > 0 {
+		db = db.Where("game_type = ?", gameType)
+	}
+	if err := db.Order("date asc, game_type asc").Find(&report.Days).Error; err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+
+	for _, day := range report.Days {
+		report.Summary.Rounds += day.Rounds
+		report.Summary.Bets += day.Bets
+		report.Summary.Payouts += day.Payouts
+		report.Summary.SwitchFees += day.SwitchFees
+		report.Summary.Ggr += day.Ggr
+	}
+	// 跨天去重的玩家数只能回到下注记录里数，目前只有大逃杀
+	if gameType == 0 || gameType == game.TypeDts {
+		stats, err := dtsRevenueStats(database.DB.WithContext(ctx), from, to)
+		if err != nil {
+			return nil, util.NewBizErr("SystemBusy", nil)
+		}
+		report.Summary.ActivePlayers = stats.Players
+	}
+	return report, nil
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"test/pkg/money"
+)
+
+func TestParseRevenueRange(t *testing.T) {
+	from, to, err := ParseRevenueRange("2026-10-01", "2026-10-18")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 结束日期当天也算在内
+	if !from.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)) || !to.Equal(time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("got [%v, %v)", from, to)
+	}
+
+	if _, _, err := ParseRevenueRange("2026-10-18", "2026-10-01"); bizKey(err) != "InvalidDateRange" {
+		t.Fatalf("reversed range: %v", err)
+	}
+	if _, _, err := ParseRevenueRange("2025-01-01", "2026-10-18"); bizKey(err) != "DateRangeTooLong" {
+		t.Fatalf("long range: %v", err)
+	}
+}
+
+func TestRevenueGgrIncludesSwitchFees(t *testing.T) {
+	stats := revenueStats{Bets: money.FromYuan(1000), Payouts: money.FromYuan(900), SwitchFees: money.FromYuan(5)}
+	if got := stats.Ggr(); got != money.FromYuan(105) {
+		t.Fatalf("ggr = %s, want 105", got)
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	myredis "test/pkg/redis"
+)
+
+// 推送流：每个频道（玩法）的广播都带一个递增的序号，最近的消息保存在 Redis 里
+// 客户端断线重连时带上 last_seq，补发缺失的消息；缺得太多就直接给全量快照
+const (
+	StreamBufferSize     = 500       // 每个频道保留的消息条数
+	StreamUserBufferSize = 20        // 每个用户保留的个人消息条数（结算结果、退款明细）
+	StreamTTL            = time.Hour // 频道长时间没有消息时自动过期
+)
+
+func streamSeqKey(channel string) string {
+	return fmt.Sprintf("ws:stream:%s:seq", channel)
+}
+
+func streamBufferKey(channel string) string {
+	return fmt.Sprintf("ws:stream:%s:buffer", channel)
+}
+
+func streamUserKey(channel string, userID int64) string {
+	return fmt.Sprintf("ws:stream:%s:user:%d", channel, userID)
+}
+
+// AppendStream 给消息分配序号并写入缓冲区，返回带 seq / channel 字段的消息
+// 多个节点同时发布时序号可能与到达顺序不完全一致，客户端按 seq 去重即可
+func AppendStream(ctx context.Context, channel string, body map[string]interface{}) ([]byte, int64, error) {
+	seq, err := myredis.RedisClient.Incr(ctx, streamSeqKey(channel)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	body["seq"] = seq
+	body["channel"] = channel
+	msg, err := json.Marshal(body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	bufferKey := streamBufferKey(channel)
+	pipe := myredis.RedisClient.TxPipeline()
+	pipe.ZAdd(ctx, bufferKey, redis.Z{Score: float64(seq), Member: msg})
+	// 只保留最近 StreamBufferSize 条
+	pipe.ZRemRangeByRank(ctx, bufferKey, 0, -StreamBufferSize-1)
+	pipe.Expire(ctx, bufferKey, StreamTTL)
+	pipe.Expire(ctx, streamSeqKey(channel), StreamTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, 0, err
+	}
+	return msg, seq, nil
+}
+
+// CurrentStreamSeq 频道当前的最新序号，全量快照里带上，客户端从这里开始接增量
+func CurrentStreamSeq(ctx context.Context, channel string) int64 {
+	seq, err := myredis.RedisClient.Get(ctx, streamSeqKey(channel)).Int64()
+	if err != nil {
+		return 0
+	}
+	return seq
+}
+
+// ReplayStream 取出 lastSeq 之后的所有消息
+// ok 为 false 表示无法补齐（缺口超出缓冲区、序号被重置），调用方应改发全量快照
+func ReplayStream(ctx context.Context, channel string, lastSeq int64) (messages [][]byte, ok bool, err error) {
+	current := CurrentStreamSeq(ctx, channel)
+	if lastSeq > current {
+		// 客户端的序号比服务端还新，说明 Redis 被清空过
+		return nil, false, nil
+	}
+	if lastSeq == current {
+		return nil, true, nil
+	}
+
+	bufferKey := streamBufferKey(channel)
+	oldest, err := myredis.RedisClient.ZRangeWithScores(ctx, bufferKey, 0, 0).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, false, err
+	}
+	if len(oldest) == 0 || int64(oldest[0].Score) > lastSeq+1 {
+		return nil, false, nil
+	}
+
+	items, err := myredis.RedisClient.ZRangeByScore(ctx, bufferKey, &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(lastSeq, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	messages = make([][]byte, 0, len(items))
+	for _, item := range items {
+		messages = append(messages, []byte(item))
+	}
+	return messages, true, nil
+}
+
+// AppendUserStream 保存发给某个用户的个人消息，score 为同时发出的那条广播的序号
+// 个人消息不占用频道序号，断线重连时按 last_seq 从这里补发
+func AppendUserStream(ctx context.Context, channel string, userID, seq int64, msg []byte) error {
+	key := streamUserKey(channel, userID)
+	pipe := myredis.RedisClient.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(seq), Member: msg})
+	pipe.ZRemRangeByRank(ctx, key, 0, -StreamUserBufferSize-1)
+	pipe.Expire(ctx, key, StreamTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ReplayUserStream 取出序号不小于 lastSeq 的个人消息
+// 包含等于 lastSeq 的：客户端收到了广播不代表也收到了紧随其后的个人消息，宁可重复（消息里带 record_id，客户端可以去重）也不漏
+func ReplayUserStream(ctx context.Context, channel string, userID, lastSeq int64) ([][]byte, error) {
+	items, err := myredis.RedisClient.ZRangeByScore(ctx, streamUserKey(channel, userID), &redis.ZRangeBy{
+		Min: strconv.FormatInt(lastSeq, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	messages := make([][]byte, 0, len(items))
+	for _, item := range items {
+		messages = append(messages, []byte(item))
+	}
+	return messages, nil
+}
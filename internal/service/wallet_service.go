@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"test/internal/dao"
+	"test/internal/model"
+	"test/pkg/database"
+	"test/pkg/money"
+	"test/pkg/util"
+)
+
+// 流水类型
+const (
+	TxTypeBet        = "bet"        // 下注（扣款）
+	TxTypePayout     = "payout"     // 派奖（本金 + 奖金）
+	TxTypeRefund     = "refund"     // 退款
+	TxTypeAdjustment = "adjustment" // 人工调账 / 期初余额
+	TxTypeSwitchFee  = "switch_fee" // 换房手续费
+	TxTypeReversal   = "reversal"   // 冲正：对局作废时撤回已派的奖金
+)
+
+// 流水关联类型
+const (
+	RefTypeRecord = "record" // LmDtsRecord
+	RefTypeGame   = "game"   // LmDtsGame
+)
+
+// HouseAccountID 平台账户，所有用户分录的对手方
+const HouseAccountID int64 = 0
+
+// WalletChange 一次余额变动
+type WalletChange struct {
+	UserID        int64
+	Type          string
+	Amount        money.Money // 正数入账，负数出账
+	RefType       string
+	RefID         int64
+	Remark        string
+	AllowNegative bool // 是否允许扣成负数（冲正、撤销派奖等场景）
+}
+
+// ChangeBalance 在调用方的事务内变更用户余额，并写入借贷两条分录
+// ⚠️ 必须传入事务 tx，余额和流水要么一起成功，要么一起回滚
+func ChangeBalance(tx *gorm.DB, change WalletChange) (*model.WalletTransaction, error) {
+	// 1. 悲观锁锁住用户行，余额快照以锁定的数据为准
+	var user model.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, change.UserID).Error; err != nil {
+		return nil, err
+	}
+
+	// 2. 金额以分为单位的整数计算，没有浮点误差
+	before := user.Amount
+	after := before + change.Amount
+	if after.IsNegative() && !change.AllowNegative {
+		return nil, util.NewBizErr("InsufficientBalance", nil)
+	}
+
+	// 3. 更新余额（已持有行锁，可以直接写绝对值）
+	if err := tx.Model(&user).UpdateColumn("amount", after).Error; err != nil {
+		return nil, err
+	}
+
+	// 4. 写入分录：用户一条，平台账户一条，金额相反
+	txNo, err := newTxNo()
+	if err != nil {
+		return nil, err
+	}
+	entry := model.WalletTransaction{
+		TxNo:          txNo,
+		UserId:        change.UserID,
+		Type:          change.Type,
+		Amount:        change.Amount,
+		BalanceBefore: before,
+		BalanceAfter:  after,
+		RefType:       change.RefType,
+		RefId:         change.RefID,
+		Remark:        change.Remark,
+	}
+	counter := model.WalletTransaction{
+		TxNo:    txNo,
+		UserId:  HouseAccountID,
+		Type:    change.Type,
+		Amount:  change.Amount.Neg(),
+		RefType: change.RefType,
+		RefId:   change.RefID,
+		Remark:  change.Remark,
+	}
+	if err := tx.Create(&[]*model.WalletTransaction{&entry, &counter}).Error; err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// newTxNo 生成 32 位十六进制交易号
+func newTxNo() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// ListWalletTransactions 分页查询用户自己的流水
+func ListWalletTransactions(ctx context.Context, userID int64, txType string, req util.PaginationReq) ([]model.WalletTransaction, int64, error) {
+	walletDao := dao.NewWalletDao(database.DB)
+	list, total, err := walletDao.ListByUser(ctx, userID, txType, req.GetOffset(), req.GetSize())
+	if err != nil {
+		return nil, 0, util.NewBizErr("SystemBusy", nil)
+	}
+	return list, total, nil
+}
+
+// AdjustBalance 后台人工调账（也用于给上线前已有余额的用户补期初分录）
+func AdjustBalance(ctx context.Context, userID int64, amount money.Money, remark string) (*model.WalletTransaction, error) {
+	var entry *model.WalletTransaction
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		entry, err = ChangeBalance(tx, WalletChange{
+			UserID: userID,
+			Type:   TxTypeAdjustment,
+			Amount: amount,
+			Remark: remark,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// WalletMismatch 余额与流水不一致的用户
+type WalletMismatch struct {
+	UserID    int64       `json:"user_id"`
+	Balance   money.Money `json:"balance"`    // users.amount
+	LedgerSum money.Money `json:"ledger_sum"` // SUM(wallet_transactions.amount)
+	Diff      money.Money `json:"diff"`       // Balance - LedgerSum
+}
+
+// WalletReconcileReport 对账结果
+type WalletReconcileReport struct {
+	CheckedUsers int64            `json:"checked_users"`
+	Mismatches   []WalletMismatch `json:"mismatches"`
+	LedgerTotal  money.Money      `json:"ledger_total"`  // 所有分录之和，复式记账下必须为 0
+	UnbalancedTx []string         `json:"unbalanced_tx"` // 借贷不平的交易号
+	Balanced     bool             `json:"balanced"`      // 全部检查通过
+}
+
+// ReconcileWallets 对账：证明每个用户的 流水之和 == 余额，且整本账借贷相平
+func ReconcileWallets(ctx context.Context) (*WalletReconcileReport, error) {
+	walletDao := dao.NewWalletDao(database.DB)
+
+	sums, err := walletDao.SumByUser(ctx)
+	if err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+	ledger := make(map[int64]money.Money, len(sums))
+	for _, item := range sums {
+		ledger[item.UserId] = item.Total
+	}
+
+	var users []model.User
+	if err := database.DB.WithContext(ctx).Select("id", "amount").Find(&users).Error; err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+
+	report := &WalletReconcileReport{
+		CheckedUsers: int64(len(users)),
+		Mismatches:   []WalletMismatch{},
+		UnbalancedTx: []string{},
+	}
+	for _, user := range users {
+		balance := user.Amount
+		sum := ledger[int64(user.ID)]
+		if balance != sum {
+			report.Mismatches = append(report.Mismatches, WalletMismatch{
+				UserID:    int64(user.ID),
+				Balance:   balance,
+				LedgerSum: sum,
+				Diff:      balance - sum,
+			})
+		}
+	}
+
+	if report.LedgerTotal, err = walletDao.SumAll(ctx); err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+	if report.UnbalancedTx, err = walletDao.UnbalancedTxNos(ctx); err != nil {
+		return nil, util.NewBizErr("SystemBusy", nil)
+	}
+
+	report.Balanced = len(report.Mismatches) == 0 && report.LedgerTotal.IsZero() && len(report.UnbalancedTx) == 0
+	return report, nil
+}
@@ -0,0 +1,254 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 多实例部署时，所有推送都先发到 Redis 频道，每个节点订阅后只投递给自己本地的连接
+const (
+	pushChannel    = "ws:push"         // 推送频道
+	nodesKey       = "ws:nodes"        // ZSet，member 为节点 ID，score 为最后一次心跳时间
+	nodeOnlineKey  = "ws:online:%s"    // Set，某个节点上的在线用户
+	nodeHeartbeat  = 10 * time.Second  // 心跳间隔
+	nodeExpiration = 3 * nodeHeartbeat // 超过这个时间没有心跳视为节点下线
+	publishTimeout = 2 * time.Second   // 发布超时，Redis 卡住时退回本地投递
+)
+
+// clusterMessage 频道里的一条推送，UserID 为 0 表示广播
+type clusterMessage struct {
+	Node   string `json:"node"`
+	UserID int64  `json:"user_id,omitempty"`
+	Data   []byte `json:"data"`
+}
+
+// Cluster 基于 Redis pub/sub 的跨节点推送
+type Cluster struct {
+	NodeID string
+	rdb    *redis.Client
+}
+
+// DefaultNodeID 未配置节点 ID 时使用 主机名-进程号
+func DefaultNodeID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// StartCluster 开启跨节点推送：订阅推送频道并定时上报本节点的在线用户，直到 ctx 结束
+// 没有调用时 Hub 退化为单机模式，Broadcast / SendToUser 直接投递本地连接
+func (h *Hub) StartCluster(ctx context.Context, rdb *redis.Client, nodeID string) error {
+	if nodeID == "" {
+		nodeID = DefaultNodeID()
+	}
+	c := &Cluster{NodeID: nodeID, rdb: rdb}
+
+	// 先确认订阅成功再切换到集群模式，否则这段时间的推送谁都收不到
+	sub := rdb.Subscribe(ctx, pushChannel)
+	if _, err := sub.Receive(ctx); err != nil {
+		_ = sub.Close()
+		return err
+	}
+
+	h.Lock()
+	h.cluster = c
+	h.Unlock()
+
+	go h.consume(ctx, sub)
+	go h.heartbeat(ctx, c)
+	return nil
+}
+
+// consume 把频道里的消息投递给本地连接，用户不在本节点的直接忽略
+func (h *Hub) consume(ctx context.Context, sub *redis.PubSub) {
+	defer sub.Close()
+	ch := sub.Channel()
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var m clusterMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &m); err != nil {
+				continue
+			}
+			if m.UserID == 0 {
+				h.BroadcastLocal(m.Data)
+			} else {
+				h.SendToUserLocal(m.UserID, m.Data)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// heartbeat 定时上报本节点存活和在线用户，节点宕机后数据随 TTL 自动过期
+func (h *Hub) heartbeat(ctx context.Context, c *Cluster) {
+	ticker := time.NewTicker(nodeHeartbeat)
+	defer ticker.Stop()
+
+	c.report(ctx, h.localUserIDs())
+	for {
+		select {
+		case <-ticker.C:
+			c.report(ctx, h.localUserIDs())
+		case <-ctx.Done():
+			// 正常退出时立即下线，不用等 TTL
+			bg := context.Background()
+			c.rdb.ZRem(bg, nodesKey, c.NodeID)
+			c.rdb.Del(bg, fmt.Sprintf(nodeOnlineKey, c.NodeID))
+			return
+		}
+	}
+}
+
+// report 全量覆盖本节点的在线用户集合，纠正 Register/Unregister 增量更新时丢失的数据
+func (c *Cluster) report(ctx context.Context, userIDs []int64) {
+	key := fmt.Sprintf(nodeOnlineKey, c.NodeID)
+	pipe := c.rdb.TxPipeline()
+	pipe.Del(ctx, key)
+	if len(userIDs) > 0 {
+		members := make([]interface{}, 0, len(userIDs))
+		for _, uid := range userIDs {
+			members = append(members, strconv.FormatInt(uid, 10))
+		}
+		pipe.SAdd(ctx, key, members...)
+	}
+	pipe.Expire(ctx, key, nodeExpiration)
+	pipe.ZAdd(ctx, nodesKey, redis.Z{Score: float64(time.Now().Unix()), Member: c.NodeID})
+	// 顺手清理已经过期的节点
+	pipe.ZRemRangeByScore(ctx, nodesKey, "-inf", strconv.FormatInt(time.Now().Add(-nodeExpiration).Unix(), 10))
+	if _, err := pipe.Exec(ctx); err != nil {
+		fmt.Printf("WebSocket 节点心跳失败 node=%s: %v\n", c.NodeID, err)
+	}
+}
+
+// publish 发布到推送频道，失败返回 error，由调用方退回本地投递
+func (c *Cluster) publish(userID int64, msg []byte) error {
+	data, err := json.Marshal(clusterMessage{Node: c.NodeID, UserID: userID, Data: msg})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	return c.rdb.Publish(ctx, pushChannel, data).Err()
+}
+
+// online 用户上线/下线时增量更新本节点的在线集合
+func (c *Cluster) online(uid int64, online bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	key := fmt.Sprintf(nodeOnlineKey, c.NodeID)
+	if online {
+		c.rdb.SAdd(ctx, key, strconv.FormatInt(uid, 10))
+	} else {
+		c.rdb.SRem(ctx, key, strconv.FormatInt(uid, 10))
+	}
+}
+
+// NodeOnline 单个节点的在线情况
+type NodeOnline struct {
+	NodeID   string `json:"node_id"`
+	Users    int64  `json:"users"`
+	LastSeen int64  `json:"last_seen"`
+}
+
+// OnlineStats 集群在线情况
+type OnlineStats struct {
+	Cluster bool         `json:"cluster"` // 是否为集群模式
+	Users   int64        `json:"users"`   // 在线用户数（去重）
+	Nodes   []NodeOnline `json:"nodes"`
+}
+
+// OnlineUserIDs 全集群在线的用户（去重），单机模式为本地连接
+func (h *Hub) OnlineUserIDs(ctx context.Context) ([]int64, error) {
+	h.RLock()
+	c := h.cluster
+	h.RUnlock()
+	if c == nil {
+		return h.localUserIDs(), nil
+	}
+
+	keys, err := c.aliveOnlineKeys(ctx)
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	members, err := c.rdb.SUnion(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	userIDs := make([]int64, 0, len(members))
+	for _, member := range members {
+		if uid, err := strconv.ParseInt(member, 10, 64); err == nil {
+			userIDs = append(userIDs, uid)
+		}
+	}
+	return userIDs, nil
+}
+
+// aliveOnlineKeys 存活节点的在线集合 Key
+func (c *Cluster) aliveOnlineKeys(ctx context.Context) ([]string, error) {
+	nodes, err := c.aliveNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		keys = append(keys, fmt.Sprintf(nodeOnlineKey, node.Member.(string)))
+	}
+	return keys, nil
+}
+
+func (c *Cluster) aliveNodes(ctx context.Context) ([]redis.Z, error) {
+	since := strconv.FormatInt(time.Now().Add(-nodeExpiration).Unix(), 10)
+	return c.rdb.ZRangeByScoreWithScores(ctx, nodesKey, &redis.ZRangeBy{Min: since, Max: "+inf"}).Result()
+}
+
+// OnlineStats 集群模式下统计所有存活节点，单机模式只统计本地连接
+func (h *Hub) OnlineStats(ctx context.Context) (*OnlineStats, error) {
+	h.RLock()
+	c := h.cluster
+	local := int64(len(h.clients))
+	h.RUnlock()
+
+	if c == nil {
+		return &OnlineStats{
+			Users: local,
+			Nodes: []NodeOnline{{NodeID: "local", Users: local, LastSeen: time.Now().Unix()}},
+		}, nil
+	}
+
+	nodes, err := c.aliveNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &OnlineStats{Cluster: true, Nodes: make([]NodeOnline, 0, len(nodes))}
+	keys := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		nodeID := node.Member.(string)
+		key := fmt.Sprintf(nodeOnlineKey, nodeID)
+		keys = append(keys, key)
+		count, err := c.rdb.SCard(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		stats.Nodes = append(stats.Nodes, NodeOnline{NodeID: nodeID, Users: count, LastSeen: int64(node.Score)})
+	}
+	if len(keys) > 0 {
+		// 同一个用户可能同时连在多个节点上，总数要去重
+		users, err := c.rdb.SUnion(ctx, keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		stats.Users = int64(len(users))
+	}
+	return stats, nil
+}
@@ -0,0 +1,351 @@
+package websocket
+
+import (
+	"compress/flate"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+// 关闭码，4000 以后为应用自定义
+const (
+	CloseNormal    = ws.CloseNormalClosure // 正常关闭
+	CloseGoingAway = ws.CloseGoingAway     // 服务端下线
+	CloseTooLarge  = ws.CloseMessageTooBig // 消息超过大小限制
+	CloseReplaced  = 4000                  // 同一用户建立了新连接，旧连接被替换
+	CloseIdle      = 4001                  // 心跳超时
+	CloseRejected  = 4002                  // 已有连接，按会话策略拒绝新连接
+	CloseTooSlow   = 4003                  // 发送缓冲一直是满的，应答发不出去
+)
+
+// Manager 管理连接的完整生命周期：读写协程、心跳、超时、关闭码和清理
+// 每个连接固定两个协程：读协程（即 Serve 所在的协程）和写协程，所有写操作都在写协程里完成
+type Manager struct {
+	Hub      *Hub
+	Upgrader ws.Upgrader
+
+	WriteWait      time.Duration // 单次写超时
+	PongWait       time.Duration // 多久没收到任何消息（包括 pong）视为断线
+	PingPeriod     time.Duration // 心跳间隔，必须小于 PongWait
+	MaxMessageSize int64         // 客户端单条消息的最大字节数
+	SendBuffer     int           // 发送缓冲的消息条数
+
+	SessionPolicy SessionPolicy // 同一用户多个连接的处理方式，默认允许多个；每个路由一个 Manager，各自设置
+	MaxSessions   int           // SessionAllowMany 时每个用户的连接上限，0 表示不限制
+
+	// 压缩：客户端支持 permessage-deflate 时，超过 CompressThreshold 字节的消息压缩后发送
+	EnableCompression bool
+	CompressionLevel  int
+	CompressThreshold int
+
+	MaxConnections int // 本节点通过这个 Manager 建立的连接上限，0 表示不限制
+	MaxPerIP       int // 同一 IP 的连接上限，0 表示不限制
+
+	mu       sync.Mutex
+	total    int
+	perIP    map[string]int
+	clients  map[*Client]struct{} // 本 Manager 上的连接，停机时逐个关闭
+	closing  bool                 // 正在停机，不再接受新连接
+	sessions sync.WaitGroup       // 每个 Serve 调用一个计数，停机时等待全部退出
+
+	// 连接建立 / 断开的回调，在连接所在的读协程里同步执行
+	OnConnect    func(client *Client)
+	OnDisconnect func(client *Client)
+}
+
+// NewManager 使用默认参数创建
+func NewManager(hub *Hub) *Manager {
+	return &Manager{
+		Hub: hub,
+		Upgrader: ws.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+			Subprotocols: Subprotocols,
+		},
+		EnableCompression: true,
+		CompressionLevel:  flate.BestSpeed,
+		CompressThreshold: 256,
+		WriteWait:         10 * time.Second,
+		PongWait:          60 * time.Second,
+		PingPeriod:        50 * time.Second,
+		MaxMessageSize:    4096,
+		SendBuffer:        256,
+		SessionPolicy:     SessionAllowMany,
+		MaxSessions:       5,
+	}
+}
+
+// 连接数超限，在升级之前返回，由调用方回复 HTTP 错误
+var (
+	ErrTooManyConnections = errors.New("websocket: too many connections")
+	ErrTooManyFromIP      = errors.New("websocket: too many connections from this ip")
+	ErrShuttingDown       = errors.New("websocket: server shutting down")
+)
+
+// UpgradeError 握手失败（不是 WebSocket 请求、版本不对、Origin 不允许等），Serve 没有写任何响应，由调用方按 Status 回复
+type UpgradeError struct {
+	Status int
+	Reason error
+}
+
+func (e *UpgradeError) Error() string {
+	return "websocket: upgrade failed: " + e.Reason.Error()
+}
+
+func (e *UpgradeError) Unwrap() error {
+	return e.Reason
+}
+
+// acquire 占用一个连接名额
+func (m *Manager) acquire(ip string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closing {
+		return ErrShuttingDown
+	}
+	if m.MaxConnections > 0 && m.total >= m.MaxConnections {
+		return ErrTooManyConnections
+	}
+	if m.perIP == nil {
+		m.perIP = make(map[string]int)
+	}
+	if m.MaxPerIP > 0 && m.perIP[ip] >= m.MaxPerIP {
+		return ErrTooManyFromIP
+	}
+	m.total++
+	m.perIP[ip]++
+	m.sessions.Add(1)
+	return nil
+}
+
+func (m *Manager) release(ip string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.total--
+	if m.perIP[ip]--; m.perIP[ip] <= 0 {
+		delete(m.perIP, ip)
+	}
+	m.sessions.Done()
+}
+
+// track 记录 / 移除本 Manager 上的连接；停机过程中才建立的连接直接关闭
+func (m *Manager) track(client *Client, add bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.clients == nil {
+		m.clients = make(map[*Client]struct{})
+	}
+	if !add {
+		delete(m.clients, client)
+		return
+	}
+	m.clients[client] = struct{}{}
+	if m.closing {
+		client.Close(CloseGoingAway, "server shutting down")
+	}
+}
+
+// Shutdown 停机：不再接受新连接，给所有连接发 CloseGoingAway，等待读写协程全部退出或 ctx 超时
+// 被升级的连接已经脱离 http.Server 的管理，http.Server.Shutdown 不会等它们，所以要先调用这里
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.closing = true
+	clients := make([]*Client, 0, len(m.clients))
+	for client := range m.clients {
+		clients = append(clients, client)
+	}
+	m.mu.Unlock()
+
+	for _, client := range clients {
+		client.Close(CloseGoingAway, "server shutting down")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.sessions.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// MessageHandler 处理客户端发来的一条消息，返回值不为 nil 时作为应答发回
+type MessageHandler func(client *Client, msg []byte) []byte
+
+// Serve 升级连接并阻塞到连接结束，返回前保证写协程已退出、连接已从 Hub 注销
+// ip 由调用方传入（gin 的 c.ClientIP()，只信任配置过的代理转发的头），用于按 IP 限制连接数
+func (m *Manager) Serve(w http.ResponseWriter, r *http.Request, ip string, uid int64, onMessage MessageHandler) error {
+	device := DeviceFromRequest(r, ip)
+	if err := m.acquire(device.IP); err != nil {
+		return err
+	}
+	defer m.release(device.IP)
+
+	upgrader := m.Upgrader
+	upgrader.EnableCompression = m.EnableCompression
+	// 握手失败时不直接写纯文本的错误，交给调用方按接口的格式回复
+	var upgradeErr *UpgradeError
+	upgrader.Error = func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+		upgradeErr = &UpgradeError{Status: status, Reason: reason}
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if upgradeErr != nil {
+		return upgradeErr
+	}
+	if err != nil {
+		return err
+	}
+	if m.EnableCompression {
+		_ = conn.SetCompressionLevel(m.CompressionLevel)
+	}
+
+	client := NewClient(uid, device, m.SendBuffer)
+	client.Query = r.URL.Query()
+	client.Protocol = conn.Subprotocol()
+	evicted, ok := m.Hub.Register(client, m.SessionPolicy, m.MaxSessions)
+	if !ok {
+		// 先升级再关闭，浏览器拿不到 HTTP 错误码，只能通过关闭码告诉客户端原因
+		msg := ws.FormatCloseMessage(CloseRejected, "session already exists")
+		_ = conn.WriteControl(ws.CloseMessage, msg, time.Now().Add(m.WriteWait))
+		return conn.Close()
+	}
+	for _, old := range evicted {
+		old.Close(CloseReplaced, "replaced by a new connection")
+	}
+	m.track(client, true)
+	defer m.track(client, false)
+
+	// 第一条消息告诉客户端自己的会话 ID
+	welcome, _ := json.Marshal(map[string]interface{}{"type": "session", "session_id": client.SessionID})
+	client.TrySend(welcome)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.writePump(conn, client)
+	}()
+
+	if m.OnConnect != nil {
+		m.OnConnect(client)
+	}
+
+	m.readPump(conn, client, onMessage)
+
+	// 清理顺序：先从 Hub 注销（不再有新的推送），再等写协程发完关闭帧，最后关闭底层连接
+	m.Hub.Unregister(client)
+	client.Close(CloseNormal, "")
+	wg.Wait()
+	_ = conn.Close()
+
+	if m.OnDisconnect != nil {
+		m.OnDisconnect(client)
+	}
+	return nil
+}
+
+// readPump 读取客户端消息直到出错；任何消息都会刷新读超时，pong 也一样
+func (m *Manager) readPump(conn *ws.Conn, client *Client, onMessage MessageHandler) {
+	conn.SetReadLimit(m.MaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(m.PongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(m.PongWait))
+	})
+
+	for {
+		messageType, msg, err := conn.ReadMessage()
+		if err != nil {
+			client.Close(closeCodeFor(err), "")
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(m.PongWait))
+
+		if onMessage == nil {
+			continue
+		}
+		// 二进制帧按协商的编码转换成 JSON
+		if messageType == ws.BinaryMessage {
+			if msg, err = DecodeToJSON(client.Protocol, msg); err != nil {
+				continue
+			}
+		}
+		// 应答必须送达：缓冲满时等一个写超时，还发不出去说明客户端收得太慢，断开让它重连
+		if reply := onMessage(client, msg); reply != nil && !client.SendWait(reply, m.WriteWait) {
+			client.Close(CloseTooSlow, "send buffer full")
+			return
+		}
+	}
+}
+
+// writePump 唯一写连接的协程：发送消息、定时 ping，连接关闭时发送关闭帧后退出
+func (m *Manager) writePump(conn *ws.Conn, client *Client) {
+	ticker := time.NewTicker(m.PingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg := <-client.Send:
+			messageType, data, err := msg.Encode(client.Protocol)
+			if err != nil {
+				continue
+			}
+			// 小消息压缩收益不大，还要多花 CPU
+			conn.EnableWriteCompression(m.EnableCompression && len(data) >= m.CompressThreshold)
+			_ = conn.SetWriteDeadline(time.Now().Add(m.WriteWait))
+			if err := conn.WriteMessage(messageType, data); err != nil {
+				client.Close(CloseGoingAway, "")
+				// 写失败后让读协程尽快退出
+				_ = conn.SetReadDeadline(time.Now())
+				return
+			}
+		case <-ticker.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(m.WriteWait))
+			if err := conn.WriteMessage(ws.PingMessage, nil); err != nil {
+				client.Close(CloseIdle, "")
+				_ = conn.SetReadDeadline(time.Now())
+				return
+			}
+		case <-client.Done():
+			msg := ws.FormatCloseMessage(client.closeCode, client.closeReason)
+			_ = conn.WriteControl(ws.CloseMessage, msg, time.Now().Add(m.WriteWait))
+			// 被替换 / 服务端主动关闭时读协程还阻塞在 ReadMessage 上，让它立即返回
+			_ = conn.SetReadDeadline(time.Now())
+			return
+		}
+	}
+}
+
+// DeviceFromRequest 从连接参数里读取设备信息：?platform=ios
+// 不自己解析 X-Forwarded-For 之类的头，客户端可以随意伪造，IP 以调用方传入的为准
+func DeviceFromRequest(r *http.Request, ip string) Device {
+	return Device{
+		Platform:  r.URL.Query().Get("platform"),
+		UserAgent: r.UserAgent(),
+		IP:        ip,
+	}
+}
+
+// closeCodeFor 读出错时回给客户端的关闭码
+func closeCodeFor(err error) int {
+	var closeErr *ws.CloseError
+	switch {
+	case errors.As(err, &closeErr):
+		return CloseNormal // 客户端主动关闭，回一个正常关闭
+	case errors.Is(err, ws.ErrReadLimit):
+		return CloseTooLarge
+	default:
+		return CloseIdle // 读超时（心跳丢失）或网络断开
+	}
+}
@@ -0,0 +1,215 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+func newTestServer(t *testing.T, m *Manager) (*httptest.Server, string) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = m.Serve(w, r, "127.0.0.1", 1, func(client *Client, msg []byte) []byte {
+			return append([]byte("echo:"), msg...)
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// dial 建立连接并读掉第一条会话消息
+func dial(t *testing.T, url string) *ws.Conn {
+	t.Helper()
+	conn, _, err := ws.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, msg, err := conn.ReadMessage()
+	if err != nil || !strings.Contains(string(msg), "session_id") {
+		t.Fatalf("welcome = %q, %v", msg, err)
+	}
+	return conn
+}
+
+func TestManagerEchoAndCleanup(t *testing.T) {
+	hub := &Hub{clients: make(map[int64]map[string]*Client)}
+	m := NewManager(hub)
+	disconnected := make(chan struct{})
+	m.OnDisconnect = func(client *Client) { close(disconnected) }
+	_, url := newTestServer(t, m)
+
+	conn := dial(t, url)
+	if err := conn.WriteMessage(ws.TextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	_, msg, err := conn.ReadMessage()
+	if err != nil || string(msg) != "echo:hi" {
+		t.Fatalf("reply = %q, %v", msg, err)
+	}
+
+	_ = conn.WriteMessage(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseNormalClosure, ""))
+	select {
+	case <-disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnDisconnect not called")
+	}
+	if len(hub.GetAllClients()) != 0 {
+		t.Fatal("client not unregistered")
+	}
+}
+
+func TestManagerReplacesOldConnection(t *testing.T) {
+	hub := &Hub{clients: make(map[int64]map[string]*Client)}
+	m := NewManager(hub)
+	m.SessionPolicy = SessionKeepNewest
+	_, url := newTestServer(t, m)
+
+	first := dial(t, url)
+	second := dial(t, url)
+	defer second.Close()
+
+	_ = first.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := first.ReadMessage()
+	var closeErr *ws.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseReplaced {
+		t.Fatalf("first connection err = %v, want close %d", err, CloseReplaced)
+	}
+
+	// 旧连接的清理不能把新连接注销掉
+	time.Sleep(100 * time.Millisecond)
+	if len(hub.GetAllClients()) != 1 {
+		t.Fatal("new connection should stay registered")
+	}
+}
+
+func TestManagerAllowManySessions(t *testing.T) {
+	hub := &Hub{clients: make(map[int64]map[string]*Client)}
+	_, url := newTestServer(t, NewManager(hub))
+
+	phone := dial(t, url)
+	defer phone.Close()
+	browser := dial(t, url)
+	defer browser.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	if n := len(hub.Sessions(1)); n != 2 {
+		t.Fatalf("sessions = %d, want 2", n)
+	}
+
+	// 发给用户的消息每个会话都要收到
+	hub.SendToUserLocal(1, []byte("hello"))
+	for _, conn := range []*ws.Conn{phone, browser} {
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if _, msg, err := conn.ReadMessage(); err != nil || string(msg) != "hello" {
+			t.Fatalf("msg = %q, %v", msg, err)
+		}
+	}
+}
+
+func TestManagerRejectNewSession(t *testing.T) {
+	hub := &Hub{clients: make(map[int64]map[string]*Client)}
+	m := NewManager(hub)
+	m.SessionPolicy = SessionRejectNew
+	_, url := newTestServer(t, m)
+
+	first := dial(t, url)
+	defer first.Close()
+
+	second, _, err := ws.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = second.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = second.ReadMessage()
+	var closeErr *ws.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseRejected {
+		t.Fatalf("second connection err = %v, want close %d", err, CloseRejected)
+	}
+}
+
+func TestManagerConnectionLimit(t *testing.T) {
+	m := NewManager(NewHub())
+	m.MaxPerIP = 1
+	_, url := newTestServer(t, m)
+
+	first := dial(t, url)
+	if _, _, err := ws.DefaultDialer.Dial(url, nil); err == nil {
+		t.Fatal("second connection from the same ip should be refused")
+	}
+
+	// 断开后名额释放
+	first.Close()
+	time.Sleep(100 * time.Millisecond)
+	dial(t, url).Close()
+}
+
+func TestManagerUpgradeError(t *testing.T) {
+	m := NewManager(NewHub())
+	// 普通的 HTTP 请求握手失败，Serve 不写响应，把状态码交给调用方
+	w := httptest.NewRecorder()
+	err := m.Serve(w, httptest.NewRequest(http.MethodGet, "/ws", nil), "127.0.0.1", 1, nil)
+	var upgradeErr *UpgradeError
+	if !errors.As(err, &upgradeErr) || upgradeErr.Status != http.StatusBadRequest {
+		t.Fatalf("err = %v, want upgrade error 400", err)
+	}
+	if w.Body.Len() != 0 {
+		t.Fatalf("body = %q, want empty", w.Body.String())
+	}
+}
+
+func TestManagerNegotiatesMsgpack(t *testing.T) {
+	hub := NewHub()
+	_, url := newTestServer(t, NewManager(hub))
+
+	dialer := ws.Dialer{Subprotocols: []string{ProtocolMsgpack}, EnableCompression: true}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != ProtocolMsgpack {
+		t.Fatalf("subprotocol = %q", conn.Subprotocol())
+	}
+
+	messageType, data, err := conn.ReadMessage()
+	if err != nil || messageType != ws.BinaryMessage {
+		t.Fatalf("welcome type = %d, %v", messageType, err)
+	}
+	welcome, err := DecodeToJSON(ProtocolMsgpack, data)
+	if err != nil || !strings.Contains(string(welcome), "session_id") {
+		t.Fatalf("welcome = %s, %v", welcome, err)
+	}
+
+	// 大消息走压缩，内容不变
+	big := `{"data":"` + strings.Repeat("x", 1024) + `"}`
+	hub.SendToUserLocal(1, []byte(big))
+	_, data, err = conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := DecodeToJSON(ProtocolMsgpack, data); string(got) != big {
+		t.Fatalf("big message = %s", got)
+	}
+}
+
+func TestParseSessionPolicy(t *testing.T) {
+	for name, want := range map[string]SessionPolicy{
+		"":            SessionAllowMany,
+		"allow_many":  SessionAllowMany,
+		"keep_newest": SessionKeepNewest,
+		"reject_new":  SessionRejectNew,
+	} {
+		if got, err := ParseSessionPolicy(name); err != nil || got != want {
+			t.Fatalf("ParseSessionPolicy(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseSessionPolicy("newest"); err == nil {
+		t.Fatal("unknown policy should fail")
+	}
+}
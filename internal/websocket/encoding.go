@@ -0,0 +1,153 @@
+package websocket
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 连接时通过 Sec-WebSocket-Protocol 协商编码，不传或者不认识的按 JSON 处理
+// 业务代码只产出 JSON，写协程按连接的编码转换，同一条广播每种编码只转换一次
+const (
+	ProtocolJSON     = "dts.json"
+	ProtocolMsgpack  = "dts.msgpack"
+	ProtocolProtobuf = "dts.protobuf" // 每一帧是一个 Frame，见 PushSchema
+)
+
+// Subprotocols 服务端支持的子协议，按优先级排列
+var Subprotocols = []string{ProtocolJSON, ProtocolMsgpack, ProtocolProtobuf}
+
+// PushSchema protobuf 编码的消息定义，通过接口公开给客户端生成代码
+//
+//go:embed proto/push.proto
+var PushSchema string
+
+var (
+	msgpackHandle          = &codec.MsgpackHandle{}
+	mapStringInterfaceType = reflect.TypeOf(map[string]interface{}(nil))
+)
+
+func init() {
+	msgpackHandle.WriteExt = true
+	msgpackHandle.RawToString = true
+	msgpackHandle.MapType = mapStringInterfaceType
+}
+
+// Message 一条待发送的消息，广播时所有连接共用一个，避免重复编码
+type Message struct {
+	JSON []byte
+
+	mu      sync.Mutex
+	encoded map[string][]byte
+}
+
+func NewMessage(data []byte) *Message {
+	return &Message{JSON: data}
+}
+
+// Encode 按子协议编码，返回 WebSocket 帧类型和内容
+func (m *Message) Encode(protocol string) (int, []byte, error) {
+	if protocol != ProtocolMsgpack && protocol != ProtocolProtobuf {
+		return ws.TextMessage, m.JSON, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if data, ok := m.encoded[protocol]; ok {
+		return ws.BinaryMessage, data, nil
+	}
+	data, err := EncodeJSON(protocol, m.JSON)
+	if err != nil {
+		return 0, nil, err
+	}
+	if m.encoded == nil {
+		m.encoded = make(map[string][]byte, 1)
+	}
+	m.encoded[protocol] = data
+	return ws.BinaryMessage, data, nil
+}
+
+// EncodeJSON 把 JSON 消息转换成 msgpack / protobuf
+func EncodeJSON(protocol string, data []byte) ([]byte, error) {
+	switch protocol {
+	case ProtocolMsgpack:
+		value, err := decodeJSONValue(data)
+		if err != nil {
+			return nil, err
+		}
+		var out []byte
+		err = codec.NewEncoderBytes(&out, msgpackHandle).Encode(value)
+		return out, err
+
+	case ProtocolProtobuf:
+		out, err := encodeFrame(data)
+		if errors.Is(err, errPbMismatch) {
+			// push.proto 里还没有定义的消息整条按 JSON 发送，不能因此丢消息
+			out = protowire.AppendTag(nil, pbFrameJSON, protowire.BytesType)
+			return protowire.AppendBytes(out, data), nil
+		}
+		return out, err
+
+	default:
+		return data, nil
+	}
+}
+
+// DecodeToJSON 客户端发来的二进制帧转换成 JSON，指令处理只认识 JSON
+func DecodeToJSON(protocol string, data []byte) ([]byte, error) {
+	switch protocol {
+	case ProtocolMsgpack:
+		var value interface{}
+		if err := codec.NewDecoderBytes(data, msgpackHandle).Decode(&value); err != nil {
+			return nil, err
+		}
+		return json.Marshal(value)
+
+	case ProtocolProtobuf:
+		return decodeFrame(data)
+
+	default:
+		return data, nil
+	}
+}
+
+// decodeJSONValue 解析 JSON，整数保持整数（默认解析成 float64，msgpack 里会变成浮点）
+func decodeJSONValue(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return normalizeNumbers(value), nil
+}
+
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeNumbers(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeNumbers(item)
+		}
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
+	}
+}
@@ -0,0 +1,200 @@
+package websocket
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	src := []byte(`{"dts_delta":{"event":"bet_placed","game_id":12,"user":{"user_id":7,"nickname":"a","room_id":2,"amount":10.5,"bonus":0},"room":{"room_id":2,"amount":-0.01}},"dts_rank":{"daily":{"period":"daily","items":[{"rank":1,"user_id":7,"profit":3}]}},"seq":7,"channel":"dts","resync":true}`)
+	var want interface{}
+	_ = json.Unmarshal(src, &want)
+
+	for _, protocol := range []string{ProtocolMsgpack, ProtocolProtobuf} {
+		encoded, err := EncodeJSON(protocol, src)
+		if err != nil {
+			t.Fatalf("%s encode: %v", protocol, err)
+		}
+		decoded, err := DecodeToJSON(protocol, encoded)
+		if err != nil {
+			t.Fatalf("%s decode: %v", protocol, err)
+		}
+		var got interface{}
+		_ = json.Unmarshal(decoded, &got)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s round trip = %s, want %s", protocol, decoded, src)
+		}
+	}
+}
+
+func TestProtobufTypedSmallerThanJSON(t *testing.T) {
+	src := []byte(`{"dts_delta":{"event":"bet_placed","game_type":1,"game_id":1024,"timestamp":1760000000,"room":{"room_id":3,"amount":1250.5},"join_people":18},"seq":5312,"channel":"dts"}`)
+	encoded, err := EncodeJSON(ProtocolProtobuf, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pbFindBytes(encoded, pbFrameJSON); ok {
+		t.Fatal("known message should be encoded with typed fields")
+	}
+	if len(encoded)*2 > len(src) {
+		t.Fatalf("protobuf frame is %d bytes, JSON is %d", len(encoded), len(src))
+	}
+}
+
+func TestProtobufUnknownMessageFallsBackToJSON(t *testing.T) {
+	src := []byte(`{"dts_delta":{"new_field":1},"seq":1}`)
+	encoded, err := EncodeJSON(ProtocolProtobuf, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeToJSON(ProtocolProtobuf, encoded)
+	if err != nil || string(decoded) != string(src) {
+		t.Fatalf("decoded = %s, %v; want %s", decoded, err, src)
+	}
+}
+
+func TestProtobufCommandIDAsString(t *testing.T) {
+	encoded, err := EncodeJSON(ProtocolProtobuf, []byte(`{"id":3,"type":"join","payload":{"game_id":9,"room_id":1,"amount":2}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeToJSON(ProtocolProtobuf, encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req Request
+	if err := json.Unmarshal(decoded, &req); err != nil || string(req.ID) != `"3"` || req.Type != CmdJoin {
+		t.Fatalf("decoded = %s, %v", decoded, err)
+	}
+}
+
+// protoField push.proto 里声明的一个字段
+type protoField struct {
+	Num      int
+	Type     string // 标量类型或消息名，map 为 value 的类型
+	Repeated bool
+	Map      bool
+}
+
+var (
+	protoMessageDecl = regexp.MustCompile(`(?s)message (\w+) \{(.*?)\n\}`)
+	protoFieldDecl   = regexp.MustCompile(`(?m)^\s*(repeated\s+)?(?:map<string,\s*(\w+)>|(\w+))\s+(\w+)\s*=\s*(\d+);`)
+)
+
+// parsePushProto 按消息名解析 push.proto 的字段（只支持这个文件用到的语法）
+func parsePushProto(t *testing.T) map[string]map[string]protoField {
+	t.Helper()
+	messages := make(map[string]map[string]protoField)
+	for _, m := range protoMessageDecl.FindAllStringSubmatch(PushSchema, -1) {
+		fields := make(map[string]protoField)
+		for _, f := range protoFieldDecl.FindAllStringSubmatch(m[2], -1) {
+			num, _ := strconv.Atoi(f[5])
+			field := protoField{Num: num, Type: f[3], Repeated: f[1] != "", Map: f[2] != ""}
+			if field.Map {
+				field.Type = f[2]
+			}
+			fields[f[4]] = field
+		}
+		messages[m[1]] = fields
+	}
+	if len(messages) == 0 {
+		t.Fatal("no message parsed from push.proto")
+	}
+	return messages
+}
+
+// protoScalarTypes 每种编码方式在 push.proto 里允许的类型，线上的 wire type 由它决定
+var protoScalarTypes = map[pbKind][]string{
+	pbVarint: {"int32", "int64", "uint64"},
+	pbMoney:  {"sint64"},
+	pbDouble: {"double"},
+	pbBool:   {"bool"},
+	pbString: {"string"},
+	pbID:     {"string"},
+	pbJSON:   {"bytes"},
+}
+
+// 字段表要和公开给客户端的 push.proto 保持一致：编号、类型（决定 wire type 和 zigzag 编码）、repeated / map 都要对上
+func TestPushSchemaMatchesProto(t *testing.T) {
+	messages := parsePushProto(t)
+	checked := make(map[string]bool)
+
+	var walk func(name string, msg pbMessage)
+	walk = func(name string, msg pbMessage) {
+		if checked[name] {
+			return
+		}
+		checked[name] = true
+		decls, ok := messages[name]
+		if !ok {
+			t.Errorf("message %s not found in push.proto", name)
+			return
+		}
+		for key, decl := range decls {
+			// Frame.json 是兜底字段，不在字段表里
+			if name == "Frame" && key == "json" {
+				if decl.Num != int(pbFrameJSON) || decl.Type != "bytes" {
+					t.Errorf("Frame.json = %s %d, want bytes %d", decl.Type, decl.Num, pbFrameJSON)
+				}
+				continue
+			}
+			if _, ok := msg[key]; !ok {
+				t.Errorf("%s.%s declared in push.proto but missing from the schema", name, key)
+			}
+		}
+		for key, field := range msg {
+			decl, ok := decls[key]
+			if !ok {
+				t.Errorf("%s.%s not found in push.proto", name, key)
+				continue
+			}
+			if decl.Num != int(field.Num) {
+				t.Errorf("%s.%s = %d, push.proto has %d", name, key, field.Num, decl.Num)
+			}
+			if decl.Repeated != field.Repeated || decl.Map != field.Map {
+				t.Errorf("%s.%s repeated/map = %v/%v, push.proto has %v/%v", name, key, field.Repeated, field.Map, decl.Repeated, decl.Map)
+			}
+			if field.Kind == pbNested {
+				if _, ok := messages[decl.Type]; !ok {
+					t.Errorf("%s.%s is a message in the schema, push.proto has %s", name, key, decl.Type)
+					continue
+				}
+				walk(decl.Type, field.Msg)
+				continue
+			}
+			allowed := protoScalarTypes[field.Kind]
+			match := false
+			for _, typ := range allowed {
+				match = match || typ == decl.Type
+			}
+			if !match {
+				t.Errorf("%s.%s has type %s in push.proto, schema encodes it as %v", name, key, decl.Type, allowed)
+			}
+		}
+	}
+	walk("Frame", pbFrame)
+
+	for name := range messages {
+		if !checked[name] {
+			t.Errorf("message %s in push.proto is not reachable from the schema", name)
+		}
+	}
+}
+
+func TestMessageEncodeCachesPerProtocol(t *testing.T) {
+	msg := NewMessage([]byte(`{"a":1}`))
+	_, first, err := msg.Encode(ProtocolMsgpack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, second, _ := msg.Encode(ProtocolMsgpack)
+	if &first[0] != &second[0] {
+		t.Fatal("msgpack encoding should be computed once per message")
+	}
+	if _, data, _ := msg.Encode(""); string(data) != `{"a":1}` {
+		t.Fatalf("default encoding = %s, want JSON", data)
+	}
+}
@@ -0,0 +1,31 @@
+package websocket
+
+import (
+	"encoding/json"
+
+	"test/pkg/response"
+)
+
+// 客户端 -> 服务端的指令类型
+const (
+	CmdInit   = "init"   // 进入当前局
+	CmdJoin   = "join"   // 下注
+	CmdSwitch = "switch" // 换房
+	CmdQuit   = "quit"   // 退出
+	CmdState  = "state"  // 拉取一次全量数据
+)
+
+// Request 客户端发来的指令：{id, type, payload}
+// ID 由客户端生成，应答时原样带回，用于把应答和请求对应起来
+type Request struct {
+	ID      json.RawMessage `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Reply 指令的应答：{id, type, code, msg, data}，code/msg/data 与 HTTP 接口一致
+type Reply struct {
+	ID   json.RawMessage `json:"id"`
+	Type string          `json:"type"`
+	response.Response
+}
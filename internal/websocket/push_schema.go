@@ -0,0 +1,468 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"test/pkg/money"
+)
+
+// proto/push.proto 的 Go 描述：JSON 字段名 -> protobuf 字段
+// 业务代码只产出 JSON，这里按字段表逐个转换，不需要 protoc 生成代码；改 push.proto 时同步修改这里
+
+type pbKind int
+
+const (
+	pbVarint pbKind = iota // int32 / int64 / uint64
+	pbMoney                // sint64，单位为分
+	pbDouble               // double
+	pbBool                 // bool
+	pbString               // string
+	pbID                   // string，指令编号：JSON 里可能是数字，统一按字符串
+	pbJSON                 // bytes，内容为 JSON
+	pbNested               // 嵌套消息
+)
+
+type pbField struct {
+	Num      protowire.Number
+	Kind     pbKind
+	Msg      pbMessage // Kind 为 pbNested 时的消息定义
+	Repeated bool      // repeated 消息，对应 JSON 数组
+	Map      bool      // map<string, 消息>，对应 JSON 对象
+}
+
+// pbMessage JSON 字段名 -> protobuf 字段
+type pbMessage map[string]pbField
+
+var (
+	pbRoomAmount = pbMessage{
+		"room_id": {Num: 1, Kind: pbVarint},
+		"amount":  {Num: 2, Kind: pbMoney},
+	}
+	pbDtsBet = pbMessage{
+		"user_id":  {Num: 1, Kind: pbVarint},
+		"nickname": {Num: 2, Kind: pbString},
+		"game_id":  {Num: 3, Kind: pbVarint},
+		"room_id":  {Num: 4, Kind: pbVarint},
+		"amount":   {Num: 5, Kind: pbMoney},
+		"bonus":    {Num: 6, Kind: pbMoney},
+	}
+	pbBetLimits = pbMessage{
+		"room_count":          {Num: 1, Kind: pbVarint},
+		"min_bet":             {Num: 2, Kind: pbMoney},
+		"max_bet":             {Num: 3, Kind: pbMoney},
+		"max_user_total":      {Num: 4, Kind: pbMoney},
+		"max_room_total":      {Num: 5, Kind: pbMoney},
+		"max_bets_per_round":  {Num: 6, Kind: pbVarint},
+		"max_switches":        {Num: 7, Kind: pbVarint},
+		"switch_lock_seconds": {Num: 8, Kind: pbVarint},
+		"switch_fee":          {Num: 9, Kind: pbMoney},
+	}
+	pbDtsState = pbMessage{
+		"game_type":           {Num: 1, Kind: pbVarint},
+		"game_id":             {Num: 2, Kind: pbVarint},
+		"start_time":          {Num: 3, Kind: pbVarint},
+		"end_time":            {Num: 4, Kind: pbVarint},
+		"state":               {Num: 5, Kind: pbVarint},
+		"timer":               {Num: 6, Kind: pbDouble},
+		"killer_room":         {Num: 7, Kind: pbVarint},
+		"pre_killer_room":     {Num: 8, Kind: pbVarint},
+		"server_seed_hash":    {Num: 9, Kind: pbString},
+		"join_people":         {Num: 10, Kind: pbVarint},
+		"max_people":          {Num: 11, Kind: pbVarint},
+		"bet_limits":          {Num: 12, Kind: pbNested, Msg: pbBetLimits},
+		"config_version":      {Num: 13, Kind: pbVarint},
+		"total_killer_amount": {Num: 14, Kind: pbMoney},
+		"user_list":           {Num: 15, Kind: pbNested, Msg: pbDtsBet, Repeated: true},
+		"room_list":           {Num: 16, Kind: pbNested, Msg: pbRoomAmount, Repeated: true},
+		"timestamp":           {Num: 17, Kind: pbVarint},
+	}
+	pbDtsDelta = pbMessage{
+		"event":               {Num: 1, Kind: pbString},
+		"game_type":           {Num: 2, Kind: pbVarint},
+		"game_id":             {Num: 3, Kind: pbVarint},
+		"timestamp":           {Num: 4, Kind: pbVarint},
+		"user":                {Num: 5, Kind: pbNested, Msg: pbDtsBet},
+		"room":                {Num: 6, Kind: pbNested, Msg: pbRoomAmount},
+		"from_room":           {Num: 7, Kind: pbNested, Msg: pbRoomAmount},
+		"join_people":         {Num: 8, Kind: pbVarint},
+		"state":               {Num: 9, Kind: pbVarint},
+		"start_time":          {Num: 10, Kind: pbVarint},
+		"end_time":            {Num: 11, Kind: pbVarint},
+		"forced":              {Num: 12, Kind: pbBool},
+		"killer_room":         {Num: 13, Kind: pbVarint},
+		"total_people":        {Num: 14, Kind: pbVarint},
+		"total_amount":        {Num: 15, Kind: pbMoney},
+		"total_bonus":         {Num: 16, Kind: pbMoney},
+		"total_killer_amount": {Num: 17, Kind: pbMoney},
+		"reason":              {Num: 18, Kind: pbString},
+	}
+	pbDtsUser = pbMessage{
+		"user_id":     {Num: 1, Kind: pbVarint},
+		"room_id":     {Num: 2, Kind: pbVarint},
+		"user_amount": {Num: 3, Kind: pbMoney},
+		"user_bonus":  {Num: 4, Kind: pbMoney},
+		"record_id":   {Num: 5, Kind: pbVarint},
+		"killer_room": {Num: 6, Kind: pbVarint},
+		"state":       {Num: 7, Kind: pbVarint},
+		"amount":      {Num: 8, Kind: pbMoney},
+		"bonus":       {Num: 9, Kind: pbMoney},
+		"refund":      {Num: 10, Kind: pbMoney},
+		"reversed":    {Num: 11, Kind: pbMoney},
+	}
+	pbRankItem = pbMessage{
+		"rank":     {Num: 1, Kind: pbVarint},
+		"user_id":  {Num: 2, Kind: pbVarint},
+		"nickname": {Num: 3, Kind: pbString},
+		"profit":   {Num: 4, Kind: pbMoney},
+	}
+	pbLeaderboard = pbMessage{
+		"period": {Num: 1, Kind: pbString},
+		"bucket": {Num: 2, Kind: pbString},
+		"items":  {Num: 3, Kind: pbNested, Msg: pbRankItem, Repeated: true},
+		"mine":   {Num: 4, Kind: pbNested, Msg: pbRankItem},
+	}
+	pbCommand = pbMessage{
+		"game_id":     {Num: 1, Kind: pbVarint},
+		"room_id":     {Num: 2, Kind: pbVarint},
+		"amount":      {Num: 3, Kind: pbMoney},
+		"client_seed": {Num: 4, Kind: pbString},
+	}
+	pbFrame = pbMessage{
+		"seq":             {Num: 1, Kind: pbVarint},
+		"channel":         {Num: 2, Kind: pbString},
+		"resync":          {Num: 3, Kind: pbBool},
+		"type":            {Num: 4, Kind: pbString},
+		"id":              {Num: 5, Kind: pbID},
+		"code":            {Num: 6, Kind: pbVarint},
+		"msg":             {Num: 7, Kind: pbString},
+		"data":            {Num: 8, Kind: pbJSON},
+		"payload":         {Num: 9, Kind: pbNested, Msg: pbCommand},
+		"session_id":      {Num: 10, Kind: pbString},
+		"timestamp":       {Num: 11, Kind: pbVarint},
+		"session_seconds": {Num: 12, Kind: pbVarint},
+		"wagered":         {Num: 13, Kind: pbMoney},
+		"net":             {Num: 14, Kind: pbMoney},
+		"dts_data":        {Num: 16, Kind: pbNested, Msg: pbDtsState},
+		"dts_delta":       {Num: 17, Kind: pbNested, Msg: pbDtsDelta},
+		"dts_user":        {Num: 18, Kind: pbNested, Msg: pbDtsUser},
+		"dts_public":      {Num: 19, Kind: pbNested, Msg: pbDtsState},
+		"dts_rank":        {Num: 20, Kind: pbNested, Msg: pbLeaderboard, Map: true},
+	}
+)
+
+// pbFrameJSON Frame.json：没有定义的消息整条以 JSON 发送
+const pbFrameJSON protowire.Number = 15
+
+var errPbMismatch = errors.New("websocket: message does not match push.proto")
+
+// encodeFrame 按 Frame 编码一条 JSON 消息；有字段不在 push.proto 里或者类型对不上时返回 errPbMismatch
+func encodeFrame(data []byte) ([]byte, error) {
+	value, err := decodeJSONValue(data)
+	if err != nil {
+		return nil, err
+	}
+	body, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errPbMismatch
+	}
+	return appendPbMessage(nil, pbFrame, body)
+}
+
+func appendPbMessage(b []byte, msg pbMessage, body map[string]interface{}) ([]byte, error) {
+	for key, value := range body {
+		field, ok := msg[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", errPbMismatch, key)
+		}
+		var err error
+		if b, err = appendPbField(b, field, value); err != nil {
+			return nil, fmt.Errorf("%w: field %q", err, key)
+		}
+	}
+	return b, nil
+}
+
+func appendPbField(b []byte, field pbField, value interface{}) ([]byte, error) {
+	// null 与不传相同
+	if value == nil {
+		return b, nil
+	}
+
+	switch {
+	case field.Repeated:
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, errPbMismatch
+		}
+		for _, item := range items {
+			var err error
+			if b, err = appendPbField(b, pbField{Num: field.Num, Kind: field.Kind, Msg: field.Msg}, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+
+	case field.Map:
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errPbMismatch
+		}
+		// map 的每一项是 {1: key, 2: value} 的消息
+		for key, item := range entries {
+			entry := protowire.AppendTag(nil, 1, protowire.BytesType)
+			entry = protowire.AppendString(entry, key)
+			entry, err := appendPbField(entry, pbField{Num: 2, Kind: field.Kind, Msg: field.Msg}, item)
+			if err != nil {
+				return nil, err
+			}
+			b = protowire.AppendTag(b, field.Num, protowire.BytesType)
+			b = protowire.AppendBytes(b, entry)
+		}
+		return b, nil
+	}
+
+	switch field.Kind {
+	case pbVarint:
+		v, ok := value.(int64)
+		if !ok {
+			return nil, errPbMismatch
+		}
+		b = protowire.AppendTag(b, field.Num, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(v)), nil
+
+	case pbMoney:
+		m, err := pbMoneyValue(value)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, field.Num, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeZigZag(m.Cents())), nil
+
+	case pbDouble:
+		var f float64
+		switch v := value.(type) {
+		case int64:
+			f = float64(v)
+		case float64:
+			f = v
+		default:
+			return nil, errPbMismatch
+		}
+		b = protowire.AppendTag(b, field.Num, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, math.Float64bits(f)), nil
+
+	case pbBool:
+		v, ok := value.(bool)
+		if !ok {
+			return nil, errPbMismatch
+		}
+		b = protowire.AppendTag(b, field.Num, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(v)), nil
+
+	case pbString, pbID:
+		var s string
+		switch v := value.(type) {
+		case string:
+			s = v
+		case int64:
+			if field.Kind != pbID {
+				return nil, errPbMismatch
+			}
+			s = fmt.Sprint(v)
+		default:
+			return nil, errPbMismatch
+		}
+		b = protowire.AppendTag(b, field.Num, protowire.BytesType)
+		return protowire.AppendString(b, s), nil
+
+	case pbJSON:
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, field.Num, protowire.BytesType)
+		return protowire.AppendBytes(b, raw), nil
+
+	case pbNested:
+		body, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errPbMismatch
+		}
+		inner, err := appendPbMessage(nil, field.Msg, body)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, field.Num, protowire.BytesType)
+		return protowire.AppendBytes(b, inner), nil
+	}
+	return nil, errPbMismatch
+}
+
+// pbMoneyValue JSON 里的金额是数字，个别消息（游戏时长提醒）是字符串，都按元解析
+func pbMoneyValue(value interface{}) (money.Money, error) {
+	switch v := value.(type) {
+	case int64:
+		return money.FromYuan(v), nil
+	case float64:
+		return money.Parse(fmt.Sprint(v))
+	case string:
+		return money.Parse(v)
+	}
+	return 0, errPbMismatch
+}
+
+// decodeFrame 把 Frame 还原成 JSON 消息
+func decodeFrame(data []byte) ([]byte, error) {
+	// 整条是 JSON 的帧原样返回
+	if raw, ok := pbFindBytes(data, pbFrameJSON); ok {
+		return raw, nil
+	}
+	body, err := consumePbMessage(data, pbFrame)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(body)
+}
+
+func pbFindBytes(data []byte, want protowire.Number) ([]byte, bool) {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, false
+		}
+		data = data[n:]
+		if num == want && typ == protowire.BytesType {
+			v, m := protowire.ConsumeBytes(data)
+			return v, m >= 0
+		}
+		m := protowire.ConsumeFieldValue(num, typ, data)
+		if m < 0 {
+			return nil, false
+		}
+		data = data[m:]
+	}
+	return nil, false
+}
+
+func consumePbMessage(data []byte, msg pbMessage) (map[string]interface{}, error) {
+	fields := make(map[protowire.Number]string, len(msg))
+	for key, field := range msg {
+		fields[field.Num] = key
+	}
+
+	body := make(map[string]interface{})
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		key, known := fields[num]
+		if !known {
+			// 不认识的字段（客户端用了更新的定义）跳过
+			m := protowire.ConsumeFieldValue(num, typ, data)
+			if m < 0 {
+				return nil, protowire.ParseError(m)
+			}
+			data = data[m:]
+			continue
+		}
+
+		value, m, err := consumePbField(data, msg[key], typ)
+		if err != nil {
+			return nil, fmt.Errorf("websocket: field %q: %w", key, err)
+		}
+		data = data[m:]
+
+		field := msg[key]
+		switch {
+		case field.Repeated:
+			list, _ := body[key].([]interface{})
+			body[key] = append(list, value)
+		case field.Map:
+			entries, _ := body[key].(map[string]interface{})
+			if entries == nil {
+				entries = make(map[string]interface{})
+			}
+			entry := value.(pbMapEntry)
+			entries[entry.Key] = entry.Value
+			body[key] = entries
+		default:
+			body[key] = value
+		}
+	}
+	return body, nil
+}
+
+type pbMapEntry struct {
+	Key   string
+	Value interface{}
+}
+
+func consumePbField(data []byte, field pbField, typ protowire.Type) (interface{}, int, error) {
+	if field.Map {
+		raw, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return nil, 0, protowire.ParseError(n)
+		}
+		entry, err := consumePbMessage(raw, pbMessage{
+			"key":   {Num: 1, Kind: pbString},
+			"value": {Num: 2, Kind: field.Kind, Msg: field.Msg},
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		key, _ := entry["key"].(string)
+		return pbMapEntry{Key: key, Value: entry["value"]}, n, nil
+	}
+
+	switch field.Kind {
+	case pbVarint, pbMoney, pbBool:
+		if typ != protowire.VarintType {
+			return nil, 0, errPbMismatch
+		}
+		v, n := protowire.ConsumeVarint(data)
+		if n < 0 {
+			return nil, 0, protowire.ParseError(n)
+		}
+		switch field.Kind {
+		case pbMoney:
+			return money.FromCents(protowire.DecodeZigZag(v)), n, nil
+		case pbBool:
+			return protowire.DecodeBool(v), n, nil
+		}
+		return int64(v), n, nil
+
+	case pbDouble:
+		if typ != protowire.Fixed64Type {
+			return nil, 0, errPbMismatch
+		}
+		v, n := protowire.ConsumeFixed64(data)
+		if n < 0 {
+			return nil, 0, protowire.ParseError(n)
+		}
+		return math.Float64frombits(v), n, nil
+	}
+
+	if typ != protowire.BytesType {
+		return nil, 0, errPbMismatch
+	}
+	raw, n := protowire.ConsumeBytes(data)
+	if n < 0 {
+		return nil, 0, protowire.ParseError(n)
+	}
+	switch field.Kind {
+	case pbString, pbID:
+		return string(raw), n, nil
+	case pbJSON:
+		return json.RawMessage(append([]byte(nil), raw...)), n, nil
+	case pbNested:
+		body, err := consumePbMessage(raw, field.Msg)
+		return body, n, err
+	}
+	return nil, 0, errPbMismatch
+}
@@ -1,42 +1,309 @@
 package websocket
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
 	"sync"
+	"time"
 )
 
 type Client struct {
-	ID   int64
-	Send chan []byte
+	ID          int64
+	SessionID   string // 每个连接唯一，同一用户可以同时有多个会话
+	Device      Device
+	ConnectedAt time.Time
+	Query       url.Values // 连接时的 URL 参数，例如断线重连的 last_seq
+	Protocol    string     // 协商出的子协议（编码），见 Subprotocols
+	Send        chan *Message
+
+	// 连接关闭后 done 被关闭，发送方据此停止投递；Send 通道本身不关闭（推送协程可能还在写）
+	done        chan struct{}
+	closeOnce   sync.Once
+	closeCode   int
+	closeReason string
+}
+
+// Device 连接的设备信息，由客户端在连接参数里上报
+type Device struct {
+	Platform  string `json:"platform"` // ios / android / web ...
+	UserAgent string `json:"user_agent"`
+	IP        string `json:"ip"`
+}
+
+// NewClient 创建一个连接并分配会话 ID，sendBuffer 为发送缓冲的消息条数
+func NewClient(uid int64, device Device, sendBuffer int) *Client {
+	return &Client{
+		ID:          uid,
+		SessionID:   newSessionID(),
+		Device:      device,
+		ConnectedAt: time.Now(),
+		Send:        make(chan *Message, sendBuffer),
+		done:        make(chan struct{}),
+	}
+}
+
+func newSessionID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Close 通知写协程发送关闭帧并断开连接，可以重复调用，以第一次的关闭码为准
+func (c *Client) Close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeCode, c.closeReason = code, reason
+		close(c.done)
+	})
+}
+
+// Done 连接关闭后返回的通道被关闭
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// SessionPolicy 同一用户在同一路由上建立多个连接时的处理方式
+type SessionPolicy int
+
+const (
+	SessionAllowMany  SessionPolicy = iota // 允许多个连接（手机 + 浏览器），超过上限时踢掉最早的
+	SessionKeepNewest                      // 只保留最新的连接
+	SessionRejectNew                       // 已有连接时拒绝新的连接
+)
+
+// ParseSessionPolicy 配置里的策略名：allow_many / keep_newest / reject_new，为空时允许多个
+func ParseSessionPolicy(name string) (SessionPolicy, error) {
+	switch name {
+	case "", "allow_many":
+		return SessionAllowMany, nil
+	case "keep_newest":
+		return SessionKeepNewest, nil
+	case "reject_new":
+		return SessionRejectNew, nil
+	}
+	return SessionAllowMany, fmt.Errorf("websocket: unknown session policy %q", name)
 }
 
 type Hub struct {
-	clients map[int64]*Client
+	clients map[int64]map[string]*Client // 用户 ID -> 会话 ID -> 连接
+	cluster *Cluster                     // nil 表示单机模式
 	sync.RWMutex
 }
 
-var GlobalHub = &Hub{
-	clients: make(map[int64]*Client),
+// NewHub 创建一个独立的连接表
+func NewHub() *Hub {
+	return &Hub{clients: make(map[int64]map[string]*Client)}
 }
 
-func (h *Hub) Register(uid int64, client *Client) {
+// GlobalHub 登录用户的推送连接
+var GlobalHub = NewHub()
+
+// SpectatorHub 未登录观众的连接（用户 ID 统一为 0），只在本节点内投递，不参与集群转发
+var SpectatorHub = NewHub()
+
+// Register 按策略登记连接，返回需要由调用方关闭的旧连接；ok 为 false 表示新连接被拒绝
+// maxSessions 只对 SessionAllowMany 生效，0 表示不限制
+func (h *Hub) Register(client *Client, policy SessionPolicy, maxSessions int) (evicted []*Client, ok bool) {
 	h.Lock()
-	defer h.Unlock()
-	h.clients[uid] = client
+	sessions := h.clients[client.ID]
+	if sessions == nil {
+		sessions = make(map[string]*Client)
+		h.clients[client.ID] = sessions
+	}
+
+	switch policy {
+	case SessionRejectNew:
+		if len(sessions) > 0 {
+			h.Unlock()
+			return nil, false
+		}
+	case SessionKeepNewest:
+		for sid, old := range sessions {
+			evicted = append(evicted, old)
+			delete(sessions, sid)
+		}
+	default:
+		for maxSessions > 0 && len(sessions) >= maxSessions {
+			oldest := oldestSession(sessions)
+			evicted = append(evicted, oldest)
+			delete(sessions, oldest.SessionID)
+		}
+	}
+	sessions[client.SessionID] = client
+	c := h.cluster
+	h.Unlock()
+
+	if c != nil {
+		c.online(client.ID, true)
+	}
+	return evicted, true
 }
 
-func (h *Hub) Unregister(uid int64) {
+func oldestSession(sessions map[string]*Client) *Client {
+	var oldest *Client
+	for _, client := range sessions {
+		if oldest == nil || client.ConnectedAt.Before(oldest.ConnectedAt) {
+			oldest = client
+		}
+	}
+	return oldest
+}
+
+// Unregister 注销连接；只有表里登记的还是这个连接时才删除，被踢掉的旧连接断开时不会影响其他会话
+func (h *Hub) Unregister(client *Client) {
 	h.Lock()
-	defer h.Unlock()
-	delete(h.clients, uid)
+	sessions := h.clients[client.ID]
+	if sessions[client.SessionID] != client {
+		h.Unlock()
+		return
+	}
+	delete(sessions, client.SessionID)
+	// 该用户在本节点已经没有连接了
+	offline := len(sessions) == 0
+	if offline {
+		delete(h.clients, client.ID)
+	}
+	c := h.cluster
+	h.Unlock()
+
+	if c != nil && offline {
+		c.online(client.ID, false)
+	}
 }
 
-// GetAllClients 返回所有在线用户，用于广播
+// Sessions 用户在本节点上的所有连接
+func (h *Hub) Sessions(uid int64) []*Client {
+	h.RLock()
+	defer h.RUnlock()
+	list := make([]*Client, 0, len(h.clients[uid]))
+	for _, client := range h.clients[uid] {
+		list = append(list, client)
+	}
+	return list
+}
+
+// GetAllClients 返回本节点的所有连接（同一用户的多个会话各算一个）
 func (h *Hub) GetAllClients() []*Client {
 	h.RLock()
 	defer h.RUnlock()
 	list := make([]*Client, 0, len(h.clients))
-	for _, c := range h.clients {
-		list = append(list, c)
+	for _, sessions := range h.clients {
+		for _, c := range sessions {
+			list = append(list, c)
+		}
 	}
 	return list
 }
+
+func (h *Hub) localUserIDs() []int64 {
+	h.RLock()
+	defer h.RUnlock()
+	list := make([]int64, 0, len(h.clients))
+	for uid := range h.clients {
+		list = append(list, uid)
+	}
+	return list
+}
+
+// SendToUser 给指定用户发送消息，集群模式下由用户所在的节点投递
+func (h *Hub) SendToUser(uid int64, msg []byte) {
+	if c := h.getCluster(); c != nil {
+		if err := c.publish(uid, msg); err == nil {
+			return
+		}
+	}
+	h.SendToUserLocal(uid, msg)
+}
+
+// Broadcast 给所有在线用户发送同一条消息，集群模式下每个节点各自投递本地连接
+func (h *Hub) Broadcast(msg []byte) {
+	if c := h.getCluster(); c != nil {
+		if err := c.publish(0, msg); err == nil {
+			return
+		}
+	}
+	h.BroadcastLocal(msg)
+}
+
+// SendToUserLocal 投递给用户在本节点上的所有会话，用户不在本节点返回 false
+func (h *Hub) SendToUserLocal(uid int64, msg []byte) bool {
+	sessions := h.Sessions(uid)
+	message := NewMessage(msg)
+	for _, client := range sessions {
+		client.TrySendMessage(message)
+	}
+	return len(sessions) > 0
+}
+
+// SendToSessionLocal 只投递给某一个会话（例如新连接补发的全量数据）
+func (h *Hub) SendToSessionLocal(uid int64, sessionID string, msg []byte) bool {
+	h.RLock()
+	client, ok := h.clients[uid][sessionID]
+	h.RUnlock()
+	if !ok {
+		return false
+	}
+	return client.TrySend(msg)
+}
+
+// SessionFreeSlots 会话发送缓冲里还能放下的消息条数，会话不存在返回 false
+func (h *Hub) SessionFreeSlots(uid int64, sessionID string) (int, bool) {
+	h.RLock()
+	client, ok := h.clients[uid][sessionID]
+	h.RUnlock()
+	if !ok {
+		return 0, false
+	}
+	return cap(client.Send) - len(client.Send), true
+}
+
+// BroadcastLocal 只投递本节点的连接，所有连接共用一个 Message，每种编码只转换一次
+func (h *Hub) BroadcastLocal(msg []byte) {
+	message := NewMessage(msg)
+	for _, client := range h.GetAllClients() {
+		client.TrySendMessage(message)
+	}
+}
+
+func (h *Hub) getCluster() *Cluster {
+	h.RLock()
+	defer h.RUnlock()
+	return h.cluster
+}
+
+// SendWait 阻塞发送，最多等待 timeout；用于请求的应答，应答不能像推送一样丢掉
+// 超时或连接已关闭时返回 false
+func (c *Client) SendWait(msg []byte, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case c.Send <- NewMessage(msg):
+		return true
+	case <-c.done:
+		return false
+	case <-timer.C:
+		return false
+	}
+}
+
+// TrySend 异步发送，不阻塞调用方；通道满说明网络卡，直接丢弃（下一次全量快照会兜底）
+// 连接已关闭时返回 false
+func (c *Client) TrySend(msg []byte) bool {
+	return c.TrySendMessage(NewMessage(msg))
+}
+
+// TrySendMessage 同 TrySend，多个连接发送同一条消息时共用编码结果
+func (c *Client) TrySendMessage(msg *Message) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.Send <- msg:
+		return true
+	default:
+		return false
+	}
+}
@@ -3,7 +3,17 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"test/internal/controller"
 	"test/internal/process"
+	"test/internal/websocket"
 	"test/pkg/config"
 	"test/pkg/database"
 	"test/pkg/redis"
@@ -33,6 +43,16 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	quit, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 3. 多实例部署：推送经 Redis pub/sub 转发，每个节点投递自己的连接
+	if config.Conf.Websocket.Cluster {
+		if err := websocket.GlobalHub.StartCluster(ctx, redis.RedisClient, config.Conf.Websocket.NodeId); err != nil {
+			panic(err)
+		}
+	}
+
 	go process.Handle(ctx)
 
 	// 4. 🔥 启动异步发奖 Worker
@@ -45,8 +65,26 @@ func main() {
 	r := route.Route()
 
 	// 服务启动
-	if err := r.Run(":8080"); err != nil {
-		return
-	}
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("服务启动失败: %v\n", err)
+			stop()
+		}
+	}()
+
+	// 收到 SIGINT / SIGTERM 后优雅停机
+	<-quit.Done()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer shutdownCancel()
 
+	// 1. 先关 WebSocket：升级后的连接不归 http.Server 管，给每个客户端发 CloseGoingAway
+	if err := controller.ShutdownWebsockets(shutdownCtx); err != nil {
+		fmt.Printf("关闭 WebSocket 连接超时: %v\n", err)
+	}
+	// 2. 停止接收新请求，等待进行中的 HTTP 请求处理完
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		fmt.Printf("HTTP 服务停止超时: %v\n", err)
+	}
+	// 3. 最后停后台任务（defer cancel）
 }
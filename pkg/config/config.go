@@ -28,6 +28,38 @@ type JwtConfig struct {
 	ExpireSeconds int64
 }
 
+// AdminConfig 后台管理配置
+type AdminConfig struct {
+	UserIds []int64 // 管理员用户 ID 白名单
+}
+
+// ServerConfig HTTP 服务配置
+type ServerConfig struct {
+	TrustedProxies []string // 可信的反向代理，只有从这些地址来的请求才读取 X-Forwarded-For，为空表示不信任任何代理
+}
+
+// WebsocketConfig 推送配置
+type WebsocketConfig struct {
+	Cluster bool   // 多实例部署时开启，推送经 Redis pub/sub 转发到所有节点
+	NodeId  string // 节点 ID，为空时使用 主机名-进程号
+
+	SessionPolicy string // 同一用户在 /api/dts/ws 上的多个连接：allow_many（默认）/ keep_newest / reject_new
+	MaxSessions   int    // allow_many 时每个用户的连接上限，0 表示不限制
+}
+
+// SpectateConfig 未登录观众的连接限制
+type SpectateConfig struct {
+	MaxConnections   int // 每个节点的观众连接上限
+	MaxPerIp         int // 同一 IP 的观众连接上限
+	ConnectPerMinute int // 同一 IP 每分钟最多建立几次连接
+}
+
+// ResponsibleGamingConfig 负责任博彩
+type ResponsibleGamingConfig struct {
+	IncreaseDelayHours  int // 放宽限制的等待时间（小时）
+	RealityCheckMinutes int // 默认的游戏时长提醒间隔（分钟），0 表示关闭
+}
+
 type LogConfig struct {
 	Level      string
 	Format     string
@@ -40,10 +72,16 @@ type LogConfig struct {
 }
 
 type Config struct {
-	Database DBConfig
-	Redis    RedisConfig
-	Jwt      JwtConfig
-	Log      LogConfig
+	Database  DBConfig
+	Redis     RedisConfig
+	Jwt       JwtConfig
+	Log       LogConfig
+	Admin     AdminConfig
+	Server    ServerConfig
+	Websocket WebsocketConfig
+	Spectate  SpectateConfig
+
+	ResponsibleGaming ResponsibleGamingConfig
 }
 
 var Conf *Config
@@ -11,19 +11,27 @@ import (
 
 var DB *gorm.DB
 
-func InitDb() {
-
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+// DSN 按配置拼接 MySQL 连接串
+func DSN() string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
 		config.Conf.Database.Username,
 		config.Conf.Database.Password,
 		config.Conf.Database.Host,
 		config.Conf.Database.Port,
 		config.Conf.Database.Database,
 	)
+}
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+// Open 只建立连接，不迁移表结构；离线工具连接数据库快照时使用
+func Open(dsn string) (*gorm.DB, error) {
+	return gorm.Open(mysql.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Error), // 只打印错误，不打印警告
 	})
+}
+
+func InitDb() {
+
+	db, err := Open(DSN())
 	if err != nil {
 		panic(err)
 	}
@@ -36,6 +44,12 @@ func InitDb() {
 		&model.Banner{},
 		&model.LmDtsGame{},
 		&model.LmDtsRecord{},
+		&model.WalletTransaction{},
+		&model.GameConfig{},
+		&model.ReconcileDiscrepancy{},
+		&model.UserGamingLimit{},
+		&model.UserGamingLimitLog{},
+		&model.DailyRevenue{},
 	)
 
 }
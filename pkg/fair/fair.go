@@ -0,0 +1,51 @@
+package fair
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// 可证明公平（Commit–Reveal）
+// 1. 开局时服务端生成 ServerSeed，只公开它的 SHA-256 哈希（承诺）
+// 2. 玩家下注时可提交自己的 ClientSeed，所有玩家的种子合成本局公共种子
+// 3. 结算时用 HMAC-SHA256(ServerSeed, ClientSeed:GameID) 决定结果，并公开 ServerSeed
+// 任何人都可以用公开的三个值重新计算结果，并核对哈希与开局承诺一致
+
+// NewServerSeed 生成服务端种子，返回种子本身和它的承诺哈希
+func NewServerSeed() (seed string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	seed = hex.EncodeToString(buf)
+	return seed, HashSeed(seed), nil
+}
+
+// HashSeed 计算种子的承诺值 (SHA-256 hex)
+func HashSeed(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:])
+}
+
+// CombineSeeds 把多个客户端种子按顺序合成一个公共种子
+// 顺序由调用方保证（例如按下注记录 ID 升序），否则结果不可复现
+func CombineSeeds(seeds []string) string {
+	return HashSeed(strings.Join(seeds, "\n"))
+}
+
+// Roll 根据三元组计算 [0, n) 范围内的结果下标
+// 取 HMAC 的前 8 个字节作为 uint64 再取模，n 很小（房间数）时取模偏差可以忽略
+func Roll(serverSeed, clientSeed string, gameID uint, n int) int {
+	if n <= 0 {
+		return 0
+	}
+	mac := hmac.New(sha256.New, []byte(serverSeed))
+	mac.Write([]byte(clientSeed + ":" + strconv.FormatUint(uint64(gameID), 10)))
+	sum := mac.Sum(nil)
+	return int(binary.BigEndian.Uint64(sum[:8]) % uint64(n))
+}
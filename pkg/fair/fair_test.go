@@ -0,0 +1,38 @@
+package fair
+
+import "testing"
+
+func TestRollIsDeterministic(t *testing.T) {
+	seed, hash, err := NewServerSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if HashSeed(seed) != hash {
+		t.Fatalf("hash mismatch: %s != %s", HashSeed(seed), hash)
+	}
+
+	clientSeed := CombineSeeds([]string{"1:abc", "2:"})
+	first := Roll(seed, clientSeed, 42, 9)
+	for i := 0; i < 10; i++ {
+		if got := Roll(seed, clientSeed, 42, 9); got != first {
+			t.Fatalf("roll changed between calls: %d != %d", got, first)
+		}
+	}
+	if first < 0 || first >= 9 {
+		t.Fatalf("roll out of range: %d", first)
+	}
+}
+
+func TestRollDependsOnInputs(t *testing.T) {
+	// 固定种子下，不同局号应当能得到不同结果，否则说明 GameID 没有参与计算
+	seen := make(map[int]bool)
+	for id := uint(1); id <= 50; id++ {
+		seen[Roll("server", "client", id, 9)] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("expected varied results, got %v", seen)
+	}
+	if Roll("server", "client", 1, 0) != 0 {
+		t.Fatal("empty range should return 0")
+	}
+}
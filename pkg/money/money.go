@@ -0,0 +1,186 @@
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+)
+
+// 金额统一用 Money 表示：内部是以分为单位的 int64，加减比较都是整数运算，不会出现浮点误差
+// 数据库列为 DECIMAL(16,2)，JSON / 表单里是两位小数的数字，例如 12.5、-0.03
+// 需要乘除（按比例分奖池等）时先转成 decimal 计算，再按明确的取整方式转回来：
+//   - FromDecimal 四舍五入到分，用于单个金额的换算
+//   - FloorDecimal 向下取整到分，用于拆分奖池，舍掉的零头由调用方统一归平台
+
+// Places 小数位数
+const Places = 2
+
+// Money 金额（分）
+type Money int64
+
+// Zero 零
+const Zero Money = 0
+
+var errTooPrecise = errors.New("money: at most 2 decimal places")
+
+// FromCents 以分构造
+func FromCents(cents int64) Money {
+	return Money(cents)
+}
+
+// FromYuan 以整元构造
+func FromYuan(yuan int64) Money {
+	return Money(yuan * 100)
+}
+
+// FromDecimal 四舍五入到分
+func FromDecimal(d decimal.Decimal) Money {
+	return Money(d.Shift(Places).Round(0).IntPart())
+}
+
+// FloorDecimal 向下取整到分（负数向负无穷取整）
+func FloorDecimal(d decimal.Decimal) Money {
+	return Money(d.Shift(Places).Floor().IntPart())
+}
+
+// FromFloat 从浮点数构造（四舍五入到分），只用于配置文件、Redis 分数等本来就是浮点的来源
+func FromFloat(f float64) Money {
+	return FromDecimal(decimal.NewFromFloat(f))
+}
+
+// Parse 解析 "12.34" 这样的字符串，超过两位小数返回错误，不做静默取整
+func Parse(s string) (Money, error) {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return 0, err
+	}
+	if !d.Equal(d.Truncate(Places)) {
+		return 0, errTooPrecise
+	}
+	return FromDecimal(d), nil
+}
+
+// Cents 以分为单位的整数
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+// Decimal 转成 decimal，用于乘除
+func (m Money) Decimal() decimal.Decimal {
+	return decimal.New(int64(m), -Places)
+}
+
+// Float64 转成浮点数，只用于排行榜分数等不参与记账的场景
+func (m Money) Float64() float64 {
+	return m.Decimal().InexactFloat64()
+}
+
+// String 两位小数的字符串，例如 "12.50"
+func (m Money) String() string {
+	return m.Decimal().StringFixed(Places)
+}
+
+func (m Money) IsPositive() bool { return m > 0 }
+func (m Money) IsNegative() bool { return m < 0 }
+func (m Money) IsZero() bool     { return m == 0 }
+
+// Neg 相反数
+func (m Money) Neg() Money {
+	return -m
+}
+
+// Abs 绝对值
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// MulRate 乘以比例后四舍五入到分
+func (m Money) MulRate(rate float64) Money {
+	return FromDecimal(m.Decimal().Mul(decimal.NewFromFloat(rate)))
+}
+
+// Sum 求和
+func Sum(items ...Money) Money {
+	var total Money
+	for _, item := range items {
+		total += item
+	}
+	return total
+}
+
+// MarshalJSON 输出为数字（不带引号），前端可以直接当数字用
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON 接受数字或字符串
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if len(data) == 0 || string(data) == "null" {
+		*m = 0
+		return nil
+	}
+	v, err := Parse(string(data))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// UnmarshalParam gin 表单 / query 绑定
+func (m *Money) UnmarshalParam(param string) error {
+	if param == "" {
+		*m = 0
+		return nil
+	}
+	v, err := Parse(param)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Value 写库时按 DECIMAL 字符串写入
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan 读库，兼容 DECIMAL 返回的字符串和聚合函数返回的数字，NULL 读成 0
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	case int64:
+		*m = FromYuan(v)
+		return nil
+	case float64:
+		*m = FromFloat(v)
+		return nil
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+}
+
+// scanString 聚合结果可能带更多小数位（例如 AVG），读库时四舍五入到分
+func (m *Money) scanString(s string) error {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return fmt.Errorf("money: cannot scan %s: %w", strconv.Quote(s), err)
+	}
+	*m = FromDecimal(d)
+	return nil
+}
@@ -0,0 +1,81 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Money
+		ok   bool
+	}{
+		{"12.34", 1234, true},
+		{"0.1", 10, true},
+		{"-0.05", -5, true},
+		{"100", 10000, true},
+		{"1.230", 123, true}, // 末尾的 0 不算多余精度
+		{"1.234", 0, false},
+		{"abc", 0, false},
+	}
+	for _, c := range cases {
+		got, err := Parse(c.in)
+		if (err == nil) != c.ok || got != c.want {
+			t.Errorf("Parse(%q) = %v, %v", c.in, got, err)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		A Money `json:"a"`
+		B Money `json:"b"`
+	}
+	if err := json.Unmarshal([]byte(`{"a":0.1,"b":"0.2"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A+v.B != 30 {
+		t.Fatalf("0.1 + 0.2 = %v", v.A+v.B)
+	}
+	out, _ := json.Marshal(v)
+	if string(out) != `{"a":0.10,"b":0.20}` {
+		t.Fatalf("got %s", out)
+	}
+}
+
+func TestScan(t *testing.T) {
+	cases := []struct {
+		src  interface{}
+		want Money
+	}{
+		{[]byte("99.99"), 9999},
+		{"0.005", 1}, // 聚合结果四舍五入到分
+		{int64(3), 300},
+		{nil, 0},
+	}
+	for _, c := range cases {
+		var m Money
+		if err := m.Scan(c.src); err != nil || m != c.want {
+			t.Errorf("Scan(%v) = %v, %v", c.src, m, err)
+		}
+	}
+}
+
+func TestRounding(t *testing.T) {
+	d := decimal.RequireFromString("3.335")
+	if got := FromDecimal(d); got != 334 {
+		t.Fatalf("FromDecimal = %v", got)
+	}
+	if got := FloorDecimal(d); got != 333 {
+		t.Fatalf("FloorDecimal = %v", got)
+	}
+	if got := FloorDecimal(d.Neg()); got != -334 {
+		t.Fatalf("FloorDecimal(neg) = %v", got)
+	}
+	if got := FromYuan(100).MulRate(0.9); got != 9000 {
+		t.Fatalf("MulRate = %v", got)
+	}
+}
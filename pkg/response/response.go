@@ -24,11 +24,16 @@ type Response struct {
 // ===================================
 
 func Success(c *gin.Context, data interface{}) {
-	c.JSON(http.StatusOK, Response{
+	c.JSON(http.StatusOK, SuccessResponse(c, data))
+}
+
+// SuccessResponse 成功的响应结构，HTTP 和 WebSocket 共用
+func SuccessResponse(c *gin.Context, data interface{}) Response {
+	return Response{
 		Code: http.StatusOK,
 		Msg:  util.TransBiz(c, "Success", nil),
 		Data: data,
-	})
+	}
 }
 
 // ===================================
@@ -36,55 +41,50 @@ func Success(c *gin.Context, data interface{}) {
 // ===================================
 
 func Fail(c *gin.Context, err error) {
+	c.JSON(http.StatusOK, FailResponse(c, err))
+}
 
-	var msg string
+// FailResponse 把错误转换成响应结构（已翻译），HTTP 和 WebSocket 共用
+func FailResponse(c *gin.Context, err error) Response {
 
 	// --- A. 判断是否为参数校验错误 (Validator) ---
 	var ve validator.ValidationErrors
 	if errors.As(err, &ve) {
 		// 使用之前的 TransValid 逻辑
-		msg = util.TransValid(c, err)
-
-		c.JSON(http.StatusOK, Response{
+		return Response{
 			Code: http.StatusBadRequest,
-			Msg:  msg,
+			Msg:  util.TransValid(c, err),
 			Data: nil,
-		})
-		return
+		}
 	}
 
 	// --- B. 判断是否为 JSON 格式错误 (比如传了字符串给 int 字段) ---
 	var unmarshalErr *json.UnmarshalTypeError
 	if errors.As(err, &unmarshalErr) {
-		c.JSON(http.StatusOK, Response{
+		return Response{
 			Code: http.StatusPaymentRequired,
 			Msg:  util.TransBiz(c, "InvalidJSON", nil), // 需在 TOML 配置
 			Data: nil,
-		})
-		return
+		}
 	}
 
 	// --- C. 判断是否为自定义业务错误 (BizError) ---
 	var bizErr *util.BizError
 	if errors.As(err, &bizErr) {
 		// 提取 Key 和 Params 进行翻译
-		msg = util.TransBiz(c, bizErr.Key, bizErr.Params)
-
-		c.JSON(http.StatusOK, Response{
+		return Response{
 			Code: http.StatusPaymentRequired,
-			Msg:  msg,
+			Msg:  util.TransBiz(c, bizErr.Key, bizErr.Params),
 			Data: nil,
-		})
-
-		return
+		}
 	}
 
 	// --- D. 其他未知错误 (系统错误) ---
 	// 生产环境建议打印日志: log.Println("System Error:", err)
-	c.JSON(http.StatusOK, Response{
+	return Response{
 		Code: http.StatusInternalServerError,
 		//Msg:  util.TransBiz(c, "SystemBusy", nil),
 		Msg:  util.TransBiz(c, err.Error(), nil),
 		Data: nil,
-	})
+	}
 }
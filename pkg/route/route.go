@@ -26,6 +26,7 @@ import (
 	"test/internal/middleware"
 	"test/pkg/config"
 	app "test/pkg/jwt"
+	"time"
 
 	swaggerFiles "github.com/swaggo/files" // 👈 导入这两个包
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -36,6 +37,10 @@ import (
 func Route() *gin.Engine {
 
 	router := gin.New()
+	// 只信任配置的代理转发的 X-Forwarded-For，否则客户端可以伪造 IP 绕过按 IP 的限制
+	if err := router.SetTrustedProxies(config.Conf.Server.TrustedProxies); err != nil {
+		panic(err)
+	}
 	router.Use(gin.Logger(), gin.Recovery())
 	router.Use(middleware.I18nMiddleware())
 	router.Use(middleware.Cors())
@@ -57,6 +62,9 @@ func Route() *gin.Engine {
 	bannerCtrl := controller.NewBannerController()
 	userCtrl := controller.NewUserController()
 	dtsCtrl := controller.NewDtsController()
+	walletCtrl := controller.NewWalletController()
+	adminCtrl := controller.NewAdminController()
+	gamingCtrl := controller.NewGamingController()
 
 	v1 := router.Group("/api")
 	{
@@ -76,7 +84,15 @@ func Route() *gin.Engine {
 			userAuth := user.Group("/")
 			userAuth.Use(middleware.JWTAuth(jwtHandler))
 			{
-				userAuth.GET("/show", userCtrl.Show) // 完整路径是 /api/user/show
+				userAuth.GET("/show", userCtrl.Show)                   // 完整路径是 /api/user/show
+				userAuth.GET("/transactions", walletCtrl.Transactions) // 钱包流水
+
+				// 负责任博彩
+				userAuth.GET("/gaming/limits", gamingCtrl.Limits)        // 当前限制和已用额度
+				userAuth.POST("/gaming/limits", gamingCtrl.UpdateLimits) // 修改限制，放宽要等待生效
+				userAuth.POST("/gaming/cool-off", gamingCtrl.CoolOff)    // 冷静期
+				userAuth.POST("/gaming/exclude", gamingCtrl.SelfExclude) // 自我排除
+				userAuth.GET("/gaming/logs", gamingCtrl.Logs)            // 修改记录
 			}
 		}
 
@@ -84,16 +100,55 @@ func Route() *gin.Engine {
 		dts := v1.Group("/dts")
 		{
 			dts.GET("/ws", middleware.WsAuth(jwtHandler), dtsCtrl.Ws)
+			dts.GET("/ws/schema", dtsCtrl.WsSchema) // protobuf 编码的消息定义
+			dts.GET("/verify", dtsCtrl.Verify)      // 公平性校验，无需登录
+			// 未登录观众的只读推送，按 IP 限制建连频率
+			dts.GET("/spectate", middleware.RateLimit("dts_spectate", time.Minute, func() int {
+				return config.Conf.Spectate.ConnectPerMinute
+			}), dtsCtrl.Spectate)
 
 			dtsAuth := dts.Group("/")
 			dtsAuth.Use(middleware.JWTAuth(jwtHandler))
 			{
-				dtsAuth.GET("/init", dtsCtrl.Init)  // 进入游戏
-				dtsAuth.GET("/quit", dtsCtrl.Quit)  // 退出游戏
-				dtsAuth.POST("/join", dtsCtrl.Join) // 加入游戏
+				dtsAuth.GET("/init", dtsCtrl.Init)       // 进入游戏
+				dtsAuth.GET("/quit", dtsCtrl.Quit)       // 退出游戏
+				dtsAuth.POST("/join", dtsCtrl.Join)      // 加入游戏
+				dtsAuth.POST("/switch", dtsCtrl.Switch)  // 换房
+				dtsAuth.GET("/history", dtsCtrl.History) // 历史开奖
+				dtsAuth.GET("/records", dtsCtrl.Records) // 我的下注记录
+				dtsAuth.GET("/stats", dtsCtrl.Stats)     // 我的战绩
+				dtsAuth.GET("/rank", dtsCtrl.Rank)       // 排行榜
 			}
 		}
 
+		// --- 后台管理 ---
+		admin := v1.Group("/admin")
+		admin.Use(middleware.JWTAuth(jwtHandler), middleware.AdminAuth())
+		{
+			admin.POST("/wallet/adjust", adminCtrl.WalletAdjust)      // 人工调账
+			admin.GET("/wallet/reconcile", adminCtrl.WalletReconcile) // 钱包对账
+			admin.GET("/bonus/stats", adminCtrl.BonusStats)           // 发奖队列积压
+			admin.GET("/bonus/dead", adminCtrl.BonusDead)             // 死信列表
+			admin.POST("/bonus/dead/replay", adminCtrl.BonusReplay)   // 重放死信
+
+			admin.GET("/reconcile/discrepancies", adminCtrl.Discrepancies)             // 对账差异列表
+			admin.POST("/reconcile/run", adminCtrl.ReconcileRun)                       // 手动对账
+			admin.POST("/reconcile/discrepancies/ignore", adminCtrl.DiscrepancyIgnore) // 忽略差异
+
+			admin.GET("/game/config", adminCtrl.GameConfig)                // 当前玩法配置
+			admin.GET("/game/config/history", adminCtrl.GameConfigHistory) // 配置历史版本
+			admin.POST("/game/config", adminCtrl.GameConfigUpdate)         // 发布新版本配置
+			admin.POST("/game/void", adminCtrl.GameVoid)                   // 作废一局并退款
+			admin.POST("/game/recover", adminCtrl.RecoveryRun)             // 崩溃恢复
+
+			admin.POST("/rank/rebuild", adminCtrl.RankRebuild) // 重建排行榜
+
+			admin.GET("/revenue/daily", adminCtrl.RevenueReport)     // 每日营收报表
+			admin.POST("/revenue/rebuild", adminCtrl.RevenueRebuild) // 重算营收
+
+			admin.GET("/ws/online", adminCtrl.WsOnline) // WebSocket 在线情况
+		}
+
 	}
 	return router
 }